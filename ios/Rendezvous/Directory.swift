@@ -0,0 +1,85 @@
+//
+//  Directory.swift
+//  Rendezvous
+//
+
+import Foundation
+import CryptoKit
+
+/// A rendezvous point's signed, versioned list of approved recipients.
+struct Directory {
+    struct Entry: Decodable {
+        let name: String
+        let publicKey: Data
+        let organization: String?
+        let contact: String?
+        let threshold: Int?
+    }
+
+    struct Claims: Decodable {
+        let epoch: UInt64
+        let hash: Data
+        let recipients: [Entry]
+    }
+
+    let raw: String
+    let claims: Claims
+
+    init?(raw: String) {
+        let jwtSegments = raw.split(separator: ".")
+        guard jwtSegments.count == 3 else { return nil }
+        var jwtPayload = jwtSegments[1]
+            .replacingOccurrences(of: "-", with: "+")
+            .replacingOccurrences(of: "_", with: "/")
+        jwtPayload += String(repeating: "=", count: (4 - jwtPayload.count % 4) % 4)
+        guard let data = Data(base64Encoded: jwtPayload),
+              let claims = try? JSONDecoder().decode(Claims.self, from: data) else { return nil }
+        self.raw = raw
+        self.claims = claims
+    }
+
+    /// Whether the signed hash commits to the listed recipients. Entries are
+    /// sorted by base64 public key, and each contributes its public key, name,
+    /// organization and contact, each prefixed with its big endian UInt32
+    /// length, then its threshold as a big endian UInt32.
+    var isConsistent: Bool {
+        var hasher = SHA256()
+        for entry in claims.recipients.sorted(by: { $0.publicKey.base64EncodedString() < $1.publicKey.base64EncodedString() }) {
+            for field in [entry.publicKey.base64EncodedString(), entry.name, entry.organization ?? "", entry.contact ?? ""] {
+                let bytes = Data(field.utf8)
+                hasher.update(data: withUnsafeBytes(of: UInt32(bytes.count).bigEndian) { Data($0) })
+                hasher.update(data: bytes)
+            }
+            hasher.update(data: withUnsafeBytes(of: UInt32(entry.threshold ?? 0).bigEndian) { Data($0) })
+        }
+        return Data(hasher.finalize()) == claims.hash
+    }
+
+    var recipients: [Recipient] {
+        claims.recipients.compactMap { entry in
+            guard let publicKey = try? Curve25519.KeyAgreement.PublicKey(rawRepresentation: entry.publicKey) else { return nil }
+            return Recipient(name: entry.name, publicKey: publicKey)
+        }
+    }
+
+    /// Compares against the last directory seen from `point`, remembering this
+    /// one if it is consistent with it. A point that rolls back its epoch, or
+    /// signs two different lists under one epoch, is showing different views to
+    /// different people.
+    func isConsistent(withLastSeenFrom point: RendezvousPoint) -> Bool {
+        let defaultsKey = "directory.\(point.url.absoluteString)"
+        let defaults = UserDefaults.standard
+
+        if let lastRaw = defaults.string(forKey: defaultsKey), let last = Directory(raw: lastRaw) {
+            if claims.epoch < last.claims.epoch {
+                return false
+            }
+            if claims.epoch == last.claims.epoch && claims.hash != last.claims.hash {
+                return false
+            }
+        }
+
+        defaults.set(raw, forKey: defaultsKey)
+        return true
+    }
+}
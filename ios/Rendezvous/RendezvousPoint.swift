@@ -19,6 +19,12 @@ struct RendezvousPoint {
 
     static let recoveryThreshold = ((2 * all.count) + 2) / 3 // at least 2/3rd of RPs, rounded up
 
+    /// The disclosure protocol this app speaks, declared with each share.
+    static let protocolVersion = 1
+
+    /// The versioned API; the JWKS stays at the point's root.
+    var apiURL: URL { url.appendingPathComponent("v1") }
+
     private struct CredentialResponse: Decodable {
         let credential: String
         let organization: String
@@ -26,7 +32,7 @@ struct RendezvousPoint {
     
     func requestCredential(completionHandler: @escaping (Credential?) -> Void) {
         DomainFronting.googleFrontedDataTask(
-            with: URLRequest(url: url.appendingPathComponent("credential"))
+            with: URLRequest(url: apiURL.appendingPathComponent("credential"))
         ) { data, response, error in
             guard let response = response as? HTTPURLResponse, response.statusCode == 200 else {
                 return completionHandler(nil)
@@ -43,18 +49,33 @@ struct RendezvousPoint {
         }.resume()
     }
     
-    func registerRecipient(_ recipient: Recipient, completion: @escaping (Bool) -> Void) throws {
-        var request = URLRequest(url: url.appendingPathComponent("register"))
-        request.httpMethod = "POST"
-        request.setValue("application/json", forHTTPHeaderField: "Content-Type")
-        request.httpBody = try JSONEncoder().encode(recipient)
+    func registerRecipient(
+        _ recipient: Recipient,
+        using privateKey: Curve25519.KeyAgreement.PrivateKey,
+        completion: @escaping (Bool) -> Void
+    ) throws {
+        let body = try JSONEncoder().encode(recipient)
+        let path = "register/\(recipient.publicKey.urlSafeBase64EncodedString())"
         
-        DomainFronting.googleFrontedDataTask(with: request) { data, response, error in
-            guard let response = response as? HTTPURLResponse, response.statusCode == 200 else {
+        // Registering or renaming a key requires proving we hold it
+        try fetchChallenge(at: path + "/challenge", using: privateKey) { authToken in
+            guard let authToken = authToken else {
                 return completion(false)
             }
-            completion(true)
-        }.resume()
+            
+            var request = URLRequest(url: apiURL.appendingPathComponent(path))
+            request.httpMethod = "POST"
+            request.setValue("application/json", forHTTPHeaderField: "Content-Type")
+            request.setValue(authToken, forHTTPHeaderField: "Authorization")
+            request.httpBody = body
+            
+            DomainFronting.googleFrontedDataTask(with: request) { data, response, error in
+                guard let response = response as? HTTPURLResponse, response.statusCode == 200 else {
+                    return completion(false)
+                }
+                completion(true)
+            }.resume()
+        }
     }
     
     private struct ChallengeResponse: Codable {
@@ -73,7 +94,19 @@ struct RendezvousPoint {
         using privateKey: Curve25519.KeyAgreement.PrivateKey,
         completion: @escaping (String?) -> Void
     ) throws {
-        let challengeReq = URLRequest(url: url.appendingPathComponent("inbox/\(recipient.publicKey.urlSafeBase64EncodedString())/challenge"))
+        try fetchChallenge(
+            at: "inbox/\(recipient.publicKey.urlSafeBase64EncodedString())/challenge",
+            using: privateKey,
+            completion: completion
+        )
+    }
+    
+    private func fetchChallenge(
+        at path: String,
+        using privateKey: Curve25519.KeyAgreement.PrivateKey,
+        completion: @escaping (String?) -> Void
+    ) throws {
+        let challengeReq = URLRequest(url: apiURL.appendingPathComponent(path))
         
         DomainFronting.googleFrontedDataTask(with: challengeReq) { data, response, error in
             guard let data = data,
@@ -115,6 +148,7 @@ struct RendezvousPoint {
         let id: UUID
         let org: String
         let verifiableShare: Disclosure.VerifiableShare
+        let `protocol`: Int
     }
     
     func checkInbox(
@@ -127,7 +161,7 @@ struct RendezvousPoint {
                 return completion(nil)
             }
             
-            var inboxReq = URLRequest(url: url.appendingPathComponent("inbox/\(recipient.publicKey.urlSafeBase64EncodedString())"))
+            var inboxReq = URLRequest(url: apiURL.appendingPathComponent("inbox/\(recipient.publicKey.urlSafeBase64EncodedString())"))
             inboxReq.setValue(authToken, forHTTPHeaderField: "Authorization")
             
             DomainFronting.googleFrontedDataTask(with: inboxReq) { data, response, error in
@@ -160,7 +194,7 @@ struct RendezvousPoint {
                 return completion(false)
             }
             
-            var inboxDeleteReq = URLRequest(url: url.appendingPathComponent("inbox/\(recipient.publicKey.urlSafeBase64EncodedString())/\(disclosureId.uuidString)"))
+            var inboxDeleteReq = URLRequest(url: apiURL.appendingPathComponent("inbox/\(recipient.publicKey.urlSafeBase64EncodedString())/\(disclosureId.uuidString)"))
             inboxDeleteReq.httpMethod = "DELETE"
             inboxDeleteReq.setValue(authToken, forHTTPHeaderField: "Authorization")
             
@@ -173,20 +207,32 @@ struct RendezvousPoint {
         }
     }
     
+    private struct DirectoryResponse: Decodable {
+        let directory: String
+    }
+    
     func requestRecipients(
         completionHandler: @escaping ([Recipient]) -> Void
     ) {
-        let request = URLRequest(url: url.appendingPathComponent("recipients"))
+        let request = URLRequest(url: apiURL.appendingPathComponent("directory"))
         
         DomainFronting.googleFrontedDataTask(with: request) { data, response, error in
             guard let response = response as? HTTPURLResponse, response.statusCode == 200,
                   let data = data,
-                  let recipients = try? JSONDecoder().decode([Recipient].self, from: data) else {
+                  let directoryResponse = try? JSONDecoder().decode(DirectoryResponse.self, from: data),
+                  let directory = Directory(raw: directoryResponse.directory) else {
+                completionHandler([])
+                return
+            }
+            
+            // Trust no recipients from a point whose directory contradicts
+            // itself or what it showed us before
+            guard directory.isConsistent, directory.isConsistent(withLastSeenFrom: self) else {
                 completionHandler([])
                 return
             }
             
-            completionHandler(recipients)
+            completionHandler(directory.recipients)
         }.resume()
     }
     
@@ -194,6 +240,7 @@ struct RendezvousPoint {
         let id: UUID
         let recipient: Data
         let verifiableShare: Disclosure.VerifiableShare
+        let `protocol`: Int
     }
     
     func submitDisclosure(
@@ -206,11 +253,12 @@ struct RendezvousPoint {
         let disclosureRequest = DiscloseRequest(
             id: disclosureId,
             recipient: recipient.publicKey.rawRepresentation,
-            verifiableShare: verifiableShare
+            verifiableShare: verifiableShare,
+            protocol: RendezvousPoint.protocolVersion
         )
         let body = try JSONEncoder().encode(disclosureRequest)
         
-        var request = URLRequest(url: url.appending(path: "disclose"))
+        var request = URLRequest(url: apiURL.appending(path: "disclose"))
         request.setRendezvousCredential(credential)
         request.httpMethod = "POST"
         request.setValue("application/json", forHTTPHeaderField: "Content-Type")
@@ -247,6 +295,7 @@ extension Array where Element == RendezvousPoint {
     
     func registerRecipient(
         recipient: Recipient,
+        using privateKey: Curve25519.KeyAgreement.PrivateKey,
         completionHandler: @escaping (Bool) -> Void
     ) throws {
         let group = DispatchGroup()
@@ -255,7 +304,7 @@ extension Array where Element == RendezvousPoint {
         
         for rp in self {
             group.enter()
-            try rp.registerRecipient(recipient) { success in
+            try rp.registerRecipient(recipient, using: privateKey) { success in
                 if !success {
                     syncQueue.async {
                         overallSuccess = false
@@ -187,7 +187,7 @@ struct ReceiveView: View {
 
     func registerRecipient() {
         do {
-            try RendezvousPoint.all.registerRecipient(recipient: recipient) { success in
+            try RendezvousPoint.all.registerRecipient(recipient: recipient, using: recipientKey) { success in
                 DispatchQueue.main.async {
                     guard success else {
                         errorState = .visible("Failed to register recipient.")
@@ -0,0 +1,117 @@
+//
+//  CompatibilityTests.swift
+//  Rendezvous
+//
+
+import XCTest
+import CryptoKit
+@testable import Rendezvous
+
+/// Checks that disclosures are interchangeable with the Go client. The
+/// vector is server/client/testdata/disclosure-vector.json, which the Go
+/// client's TestDisclosure_SharedVector opens too, so the two must change
+/// together.
+final class CompatibilityTests: XCTestCase {
+
+    private struct Vector: Decodable {
+        let id: UUID
+        let text: String
+        let author: String
+        let recipientPrivateKey: Data
+        let shares: [Disclosure.VerifiableShare]
+    }
+
+    private let vectorJSON = """
+    {
+      "id": "E621E1F8-C36C-495A-93FC-0C247A3E6E5F",
+      "text": "The password is swordfish",
+      "author": "nora",
+      "recipientPrivateKey": "Zl0GmNvI+5Wvwlw6TZzygNh6WFt5mSQ8pgCP0DJYl18=",
+      "shares": [
+        {
+          "data": "AUVqE4iRJNM911jJQCNBXaojo1/vhd5YkT980WOap7COWoLX5Q/2AFOvELi8+hos9c6D/pb51YEfUrg2kHuqhXDK+gOw4pmeSlMhKM3/jqes2WdPE+NsqWl1IBMiUTB9mau+lm3dUCYCtY6R9JQiOa9S/Bz0e70RWrgLG1U=",
+          "commitment": "obGU14yqRXnAq86OZDhPqFeu7W+Hgo9IyQslS1CcToo=",
+          "ephemeralKey": "4p11IZEUmLg37WktEqgVh4mOCsP2II6sEGm8qC+2tjM="
+        },
+        {
+          "data": "AorXIA41R7dzrauXnYnJ6znEdkra9Z6mDbplj5JXRzXZdmKkndRQhl7LLvlxWe1SUmp6tagmFnQ1OZ38kxiAWSMGPGmp83sCNKUjk71K4LA4ms3BzkR2dQFDim6Jkxu0JCxTdN3dartpSroNwbM1grNBLBR7MC/9hUnv8wQ=",
+          "commitment": "xwu97ha4sl44Fnw5CWibEzQhR0a7WN/7Zmon2pOlXuk=",
+          "ephemeralKey": "4p11IZEUmLg37WktEqgVh4mOCsP2II6sEGm8qC+2tjM="
+        },
+        {
+          "data": "A8+8MYWgZmJJcvpU1u+xcEiZzLDJLFcFeTCbTDTl7r8dm8t8tZ0yDawezcbDOEl4xv8tjEuaVyfa6Xe6kjlv5BJCfk9X/CV2HvfU+mTQM729Uqu7hdCJyNBR7EUZJALzT1EIKkTdfDm5H1950q446065leX3CahQOe+zq8I=",
+          "commitment": "8RE0Kz23vImeRe3pEMlhAVngQixgO6crnxwllTgXuQA=",
+          "ephemeralKey": "4p11IZEUmLg37WktEqgVh4mOCsP2II6sEGm8qC+2tjM="
+        }
+      ]
+    }
+    """
+
+    /// Shares split the Go way open with SwiftySSS, and their commitments
+    /// match CryptoKit's.
+    func testOpensGoShares() throws {
+        let vector = try JSONDecoder().decode(Vector.self, from: Data(vectorJSON.utf8))
+        let recipientKey = try Curve25519.KeyAgreement.PrivateKey(rawRepresentation: vector.recipientPrivateKey)
+
+        for share in vector.shares {
+            XCTAssertTrue(share.verify(id: vector.id, privateKey: recipientKey))
+        }
+        for pair in [(0, 1), (0, 2), (2, 1)] {
+            let shares = [vector.shares[pair.0], vector.shares[pair.1]]
+            let encrypted = try Disclosure.Encrypted.reconstruct(from: shares)
+            let disclosure = try encrypted.decrypt(using: recipientKey, ephemeralKey: shares[0].ephemeralKey)
+            XCTAssertEqual(disclosure.id, vector.id)
+            XCTAssertEqual(disclosure.text, vector.text)
+            XCTAssertEqual(disclosure.author, vector.author)
+        }
+    }
+
+    /// Shares split by SwiftySSS combine the Go way: one byte of x, then one
+    /// byte of y per ciphertext byte, over GF(2^8) with the AES polynomial.
+    func testGoCombinesSwiftShares() throws {
+        let disclosure = Disclosure(text: "The password is swordfish", author: "nora")
+        let recipientKey = Curve25519.KeyAgreement.PrivateKey()
+        let recipient = Recipient(name: "test", publicKey: recipientKey.publicKey)
+
+        let shares = try disclosure.encryptedVerifiableShares(recipient: recipient, numberOfShares: 3, recoveryThreshold: 2)
+        let ciphertext = Self.combine([shares[2].data, shares[0].data])
+        let decrypted = try Disclosure.Encrypted(ciphertext: ciphertext)
+            .decrypt(using: recipientKey, ephemeralKey: shares[0].ephemeralKey)
+        XCTAssertEqual(decrypted.text, disclosure.text)
+    }
+
+    /// Lagrange interpolation at zero, as the Go shamir package does it.
+    private static func combine(_ shares: [Data]) -> Data {
+        let xs = shares.map { $0[$0.startIndex] }
+        var secret = Data()
+        for j in 1..<shares[0].count {
+            var y: UInt8 = 0
+            for (i, share) in shares.enumerated() {
+                var numerator: UInt8 = 1
+                var denominator: UInt8 = 1
+                for (k, x) in xs.enumerated() where k != i {
+                    numerator = mul(numerator, x)
+                    denominator = mul(denominator, x ^ xs[i])
+                }
+                y ^= mul(share[share.startIndex + j], mul(numerator, inverse(denominator)))
+            }
+            secret.append(y)
+        }
+        return secret
+    }
+
+    private static func mul(_ a: UInt8, _ b: UInt8) -> UInt8 {
+        var a = UInt16(a), b = b, product: UInt16 = 0
+        while b != 0 {
+            if b & 1 != 0 { product ^= a }
+            a <<= 1
+            if a & 0x100 != 0 { a ^= 0x11b }
+            b >>= 1
+        }
+        return UInt8(product)
+    }
+
+    private static func inverse(_ a: UInt8) -> UInt8 {
+        (UInt8(1)...UInt8(255)).first { mul(a, $0) == 1 }!
+    }
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+)
+
+const adminUsage = `usage: rendezvous-point admin [-server url] [-token-file file] <command>
+
+commands:
+  list [-status pending|approved|rejected|suspended]
+  approve|reject|suspend|pending <publicKey> [-organization name] [-contact address] [-notes text]
+`
+
+// loadAdminToken reads the admin token from path, falling back to
+// $RENDEZVOUS_ADMIN_TOKEN.
+func loadAdminToken(path string) (string, error) {
+	if path == "" {
+		return os.Getenv("RENDEZVOUS_ADMIN_TOKEN"), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// runAdmin reviews recipients through a running server's admin API.
+func runAdmin(args []string) error {
+	flags := flag.NewFlagSet("admin", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), adminUsage) }
+	serverURL := flags.String("server", "http://localhost:8080", "Base URL of the rendezvous point")
+	tokenFile := flags.String("token-file", "", "File holding the admin token (defaults to $RENDEZVOUS_ADMIN_TOKEN)")
+	flags.Parse(args)
+
+	token, err := loadAdminToken(*tokenFile)
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("no admin token: set -token-file or $RENDEZVOUS_ADMIN_TOKEN")
+	}
+	client := adminClient{base: strings.TrimSuffix(*serverURL, "/"), token: token}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	switch command := flags.Arg(0); command {
+	case "list":
+		return client.list(flags.Args()[1:])
+	case "approve", "reject", "suspend", "pending":
+		status := map[string]types.RecipientStatus{
+			"approve": types.RecipientApproved,
+			"reject":  types.RecipientRejected,
+			"suspend": types.RecipientSuspended,
+			"pending": types.RecipientPending,
+		}[command]
+		return client.review(status, flags.Args()[1:])
+	default:
+		return fmt.Errorf("unknown admin command %q", command)
+	}
+}
+
+type adminClient struct {
+	base  string
+	token string
+}
+
+func (a adminClient) list(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	status := flags.String("status", "", "Only list recipients with this status")
+	flags.Parse(args)
+
+	path := "/v1/admin/recipients"
+	if *status != "" {
+		path += "?status=" + *status
+	}
+	var recipients []types.Recipient
+	if err := a.do(http.MethodGet, path, nil, &recipients); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tNAME\tORGANIZATION\tCONTACT\tPUBLIC KEY")
+	for _, r := range recipients {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Status, r.Name, r.Organization, r.Contact, r.PublicKey)
+	}
+	return w.Flush()
+}
+
+func (a adminClient) review(status types.RecipientStatus, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing public key")
+	}
+	publicKey, err := decodeKey(args[0])
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet(string(status), flag.ExitOnError)
+	review := types.RecipientReview{Status: status}
+	flags.Func("organization", "Organization the recipient belongs to", func(v string) error { review.Organization = &v; return nil })
+	flags.Func("contact", "How to reach the recipient", func(v string) error { review.Contact = &v; return nil })
+	flags.Func("notes", "How the recipient was verified", func(v string) error { review.Notes = &v; return nil })
+	flags.Parse(args[1:])
+
+	var r types.Recipient
+	if err := a.do(http.MethodPut, "/v1/admin/recipients/"+base64.RawURLEncoding.EncodeToString(publicKey), review, &r); err != nil {
+		return err
+	}
+	fmt.Printf("%s %q is %s\n", r.PublicKey, r.Name, r.Status)
+	return nil
+}
+
+func (a adminClient) do(method string, path string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, a.base+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr types.ErrorResponse
+		if err := json.Unmarshal(data, &apiErr); err == nil && apiErr.Code != "" {
+			return fmt.Errorf("%s %s: %s (%s)", method, path, apiErr.Message, apiErr.Code)
+		}
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, result)
+}
+
+// decodeKey accepts a public key in standard or URL-safe base64.
+func decodeKey(encoded string) ([]byte, error) {
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawURLEncoding, base64.URLEncoding} {
+		if key, err := encoding.DecodeString(encoded); err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid public key %q", encoded)
+}
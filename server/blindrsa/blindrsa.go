@@ -0,0 +1,177 @@
+// Package blindrsa implements RSA blind signatures (RFC 9474) in the
+// RSABSSA-SHA384-PSSZERO-Deterministic variant: messages are signed as
+// given and encoded with EMSA-PSS using an empty salt, so a finalized
+// signature is an ordinary RSASSA-PSS signature over SHA-384.
+//
+// Messages should be unpredictable, such as a random token, since the
+// deterministic variant does not randomize them.
+package blindrsa
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+var (
+	ErrInvalidSignature = errors.New("blindrsa: invalid signature")
+	ErrInvalidMessage   = errors.New("blindrsa: invalid blinded message")
+)
+
+// State is what a client keeps between blinding a message and finalizing
+// the signature over it.
+type State struct {
+	inverse *big.Int
+}
+
+// Blind encodes msg and blinds it under pub, returning the blinded message
+// to send to the signer.
+func Blind(pub *rsa.PublicKey, msg []byte, random io.Reader) ([]byte, *State, error) {
+	encoded, err := encodePSS(msg, pub.N.BitLen()-1)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := new(big.Int).SetBytes(encoded)
+	if new(big.Int).GCD(nil, nil, m, pub.N).Cmp(bigOne) != 0 {
+		return nil, nil, ErrInvalidMessage
+	}
+
+	r, inverse, err := blindingFactor(pub.N, random)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	x := new(big.Int).Exp(r, big.NewInt(int64(pub.E)), pub.N)
+	z := x.Mul(x, m).Mod(x, pub.N)
+	return z.FillBytes(make([]byte, pub.Size())), &State{inverse: inverse}, nil
+}
+
+// BlindSign signs a blinded message. The signer learns nothing about the
+// message it signs.
+func BlindSign(priv *rsa.PrivateKey, blinded []byte) ([]byte, error) {
+	m := new(big.Int).SetBytes(blinded)
+	if len(blinded) != priv.Size() || m.Cmp(priv.N) >= 0 {
+		return nil, ErrInvalidMessage
+	}
+
+	// The exponentiation in math/big is not constant time, so it is done
+	// on m blinded by a fresh random factor the caller cannot choose.
+	r, inverse, err := blindingFactor(priv.N, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	c := new(big.Int).Exp(r, big.NewInt(int64(priv.E)), priv.N)
+	c.Mul(c, m).Mod(c, priv.N)
+	s := c.Exp(c, priv.D, priv.N)
+	s.Mul(s, inverse).Mod(s, priv.N)
+
+	// Check the result, so a faulty computation never leaks the key.
+	check := new(big.Int).Exp(s, big.NewInt(int64(priv.E)), priv.N)
+	if check.Cmp(m) != 0 {
+		return nil, errors.New("blindrsa: signing failed")
+	}
+	return s.FillBytes(make([]byte, priv.Size())), nil
+}
+
+// Finalize unblinds the signer's response into a signature over msg,
+// verifying it before returning.
+func Finalize(pub *rsa.PublicKey, msg []byte, state *State, blindSig []byte) ([]byte, error) {
+	z := new(big.Int).SetBytes(blindSig)
+	if len(blindSig) != pub.Size() || z.Cmp(pub.N) >= 0 {
+		return nil, ErrInvalidSignature
+	}
+
+	s := z.Mul(z, state.inverse).Mod(z, pub.N)
+	sig := s.FillBytes(make([]byte, pub.Size()))
+	if err := Verify(pub, msg, sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// Verify checks a finalized signature over msg. Only the empty salt of the
+// PSSZERO variant is accepted. crypto/rsa cannot be asked for that, since a
+// SaltLength of 0 there means any salt length, so the encoding is
+// recomputed and compared instead.
+func Verify(pub *rsa.PublicKey, msg []byte, sig []byte) error {
+	s := new(big.Int).SetBytes(sig)
+	if len(sig) != pub.Size() || s.Cmp(pub.N) >= 0 {
+		return ErrInvalidSignature
+	}
+	encoded, err := encodePSS(msg, pub.N.BitLen()-1)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	m := s.Exp(s, big.NewInt(int64(pub.E)), pub.N)
+	if m.Cmp(new(big.Int).SetBytes(encoded)) != 0 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+var bigOne = big.NewInt(1)
+
+// blindingFactor returns a random r invertible modulo n, and its inverse.
+func blindingFactor(n *big.Int, random io.Reader) (*big.Int, *big.Int, error) {
+	for {
+		r, err := rand.Int(random, n)
+		if err != nil {
+			return nil, nil, err
+		}
+		if r.Sign() == 0 {
+			continue
+		}
+		if inverse := new(big.Int).ModInverse(r, n); inverse != nil {
+			return r, inverse, nil
+		}
+	}
+}
+
+// encodePSS is EMSA-PSS-ENCODE (RFC 8017, section 9.1.1) with SHA-384,
+// MGF1-SHA-384 and an empty salt.
+func encodePSS(msg []byte, emBits int) ([]byte, error) {
+	const hashLen = sha512.Size384
+	emLen := (emBits + 7) / 8
+	if emLen < hashLen+2 {
+		return nil, errors.New("blindrsa: key too small")
+	}
+
+	mHash := sha512.Sum384(msg)
+	h := sha512.New384()
+	h.Write(make([]byte, 8))
+	h.Write(mHash[:])
+	hash := h.Sum(nil)
+
+	em := make([]byte, emLen)
+	db := em[:emLen-hashLen-1]
+	db[len(db)-1] = 0x01
+	mgf1XOR(db, hash)
+	db[0] &= 0xff >> (8*emLen - emBits)
+
+	copy(em[emLen-hashLen-1:], hash)
+	em[emLen-1] = 0xbc
+	return em, nil
+}
+
+// mgf1XOR XORs out with MGF1-SHA-384 of seed.
+func mgf1XOR(out []byte, seed []byte) {
+	var counter [4]byte
+	for done := 0; done < len(out); {
+		h := sha512.New384()
+		h.Write(seed)
+		h.Write(counter[:])
+		for _, b := range h.Sum(nil) {
+			if done == len(out) {
+				break
+			}
+			out[done] ^= b
+			done++
+		}
+		binary.BigEndian.PutUint32(counter[:], binary.BigEndian.Uint32(counter[:])+1)
+	}
+}
@@ -0,0 +1,89 @@
+package blindrsa
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	priv, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	return priv
+}
+
+func TestRoundTrip(t *testing.T) {
+	priv := newTestKey(t)
+	msg := []byte("a random token")
+
+	blinded, state, err := Blind(&priv.PublicKey, msg, rand.Reader)
+	require.NoError(t, err)
+	assert.Len(t, blinded, priv.Size())
+
+	blindSig, err := BlindSign(priv, blinded)
+	require.NoError(t, err)
+
+	sig, err := Finalize(&priv.PublicKey, msg, state, blindSig)
+	require.NoError(t, err)
+	assert.NoError(t, Verify(&priv.PublicKey, msg, sig))
+
+	// The finalized signature is a standard RSASSA-PSS signature.
+	digest := sha512.Sum384(msg)
+	assert.NoError(t, rsa.VerifyPSS(&priv.PublicKey, crypto.SHA384, digest[:], sig, nil))
+}
+
+func TestBlindingHidesMessage(t *testing.T) {
+	priv := newTestKey(t)
+	msg := []byte("a random token")
+
+	first, _, err := Blind(&priv.PublicKey, msg, rand.Reader)
+	require.NoError(t, err)
+	second, _, err := Blind(&priv.PublicKey, msg, rand.Reader)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestVerifyRejects(t *testing.T) {
+	priv := newTestKey(t)
+	msg := []byte("a random token")
+
+	blinded, state, err := Blind(&priv.PublicKey, msg, rand.Reader)
+	require.NoError(t, err)
+	blindSig, err := BlindSign(priv, blinded)
+	require.NoError(t, err)
+	sig, err := Finalize(&priv.PublicKey, msg, state, blindSig)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, Verify(&priv.PublicKey, []byte("another token"), sig), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify(&newTestKey(t).PublicKey, msg, sig), ErrInvalidSignature)
+
+	tampered := append([]byte{}, sig...)
+	tampered[10] ^= 1
+	assert.ErrorIs(t, Verify(&priv.PublicKey, msg, tampered), ErrInvalidSignature)
+
+	// A salted PSS signature over the same message is not one of this
+	// variant's.
+	digest := sha512.Sum384(msg)
+	salted, err := rsa.SignPSS(rand.Reader, priv, crypto.SHA384, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	require.NoError(t, err)
+	require.NoError(t, rsa.VerifyPSS(&priv.PublicKey, crypto.SHA384, digest[:], salted, nil))
+	assert.ErrorIs(t, Verify(&priv.PublicKey, msg, salted), ErrInvalidSignature)
+
+	// A signature finalized for a different message does not verify.
+	_, err = Finalize(&priv.PublicKey, []byte("another token"), state, blindSig)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestBlindSignRejectsOversizedMessage(t *testing.T) {
+	priv := newTestKey(t)
+
+	_, err := BlindSign(priv, priv.N.Bytes())
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+	_, err = BlindSign(priv, []byte{1, 2, 3})
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+}
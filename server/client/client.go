@@ -0,0 +1,264 @@
+// Package client speaks the rendezvous point API for whistleblowers and
+// recipients. Its disclosures, shares and challenge answers are
+// interchangeable with the iOS app's.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/berkmancenter/rendezvous-point/directory"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Protocol is the disclosure protocol version this package speaks.
+const Protocol = 1
+
+// Error is a failure a rendezvous point reported.
+type Error struct {
+	Status int
+	types.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Point is one rendezvous point.
+type Point struct {
+	// URL is the point's root, without the /v1 prefix.
+	URL string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Header is sent with every request, such as the header a point run
+	// with -remote-ip-header takes the caller's address from in testing.
+	Header http.Header
+}
+
+func NewPoint(url string) *Point {
+	return &Point{URL: strings.TrimSuffix(url, "/")}
+}
+
+// Credential is a JWT a point issued, good for one disclosure per
+// recipient at that point.
+type Credential struct {
+	Point        *Point
+	Organization string
+	Raw          string
+}
+
+// Credential requests a credential for the caller's organization, which
+// the point resolves from the connecting address.
+func (p *Point) Credential(ctx context.Context) (*Credential, error) {
+	var resp struct {
+		Organization string `json:"organization"`
+		Credential   string `json:"credential"`
+	}
+	if err := p.do(ctx, http.MethodGet, "/credential", "", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &Credential{Point: p, Organization: resp.Organization, Raw: resp.Credential}, nil
+}
+
+func (p *Point) Info(ctx context.Context) (*types.InfoResponse, error) {
+	var info types.InfoResponse
+	if err := p.do(ctx, http.MethodGet, "/info", "", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Directory fetches the point's signed directory of approved recipients
+// and checks its signature and hash.
+func (p *Point) Directory(ctx context.Context) (*directory.Claims, error) {
+	var resp types.DirectoryResponse
+	if err := p.do(ctx, http.MethodGet, "/directory", "", nil, &resp); err != nil {
+		return nil, err
+	}
+	var jwks types.JWKS
+	if err := p.doURL(ctx, http.MethodGet, p.URL+"/.well-known/log-jwks.json", "", nil, &jwks); err != nil {
+		return nil, err
+	}
+	return directory.Parse(resp.Directory, directory.KeyFunc(jwks))
+}
+
+// Disclose submits one share of a disclosure for recipient under
+// credential, which must have been issued by p.
+func (p *Point) Disclose(ctx context.Context, credential *Credential, recipient []byte, id string, share types.VerifiableShare) error {
+	req := types.DisclosureRequest{
+		ID:              id,
+		Recipient:       base64.StdEncoding.EncodeToString(recipient),
+		VerifiableShare: share,
+		Protocol:        Protocol,
+	}
+	return p.do(ctx, http.MethodPost, "/disclose", "Bearer "+credential.Raw, req, nil)
+}
+
+// Register registers, or renames, the recipient holding privateKey.
+// Registrations are listed once the operator approves them.
+func (p *Point) Register(ctx context.Context, privateKey []byte, name string) error {
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return err
+	}
+	path := "/register/" + base64.RawURLEncoding.EncodeToString(publicKey)
+	authorization, err := p.answerChallenge(ctx, path+"/challenge", privateKey)
+	if err != nil {
+		return err
+	}
+	req := types.Recipient{Name: name, PublicKey: base64.StdEncoding.EncodeToString(publicKey)}
+	return p.do(ctx, http.MethodPost, path, authorization, req, nil)
+}
+
+// Inbox returns the shares released to the recipient holding privateKey.
+// They are not yet verified.
+func (p *Point) Inbox(ctx context.Context, privateKey []byte) ([]types.InboxResponse, error) {
+	path, err := inboxPath(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := p.answerChallenge(ctx, path+"/challenge", privateKey)
+	if err != nil {
+		return nil, err
+	}
+	var shares []types.InboxResponse
+	if err := p.do(ctx, http.MethodGet, path, authorization, nil, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// DeleteShare deletes the share of disclosure id from the recipient's
+// inbox.
+func (p *Point) DeleteShare(ctx context.Context, privateKey []byte, id string) error {
+	path, err := inboxPath(privateKey)
+	if err != nil {
+		return err
+	}
+	authorization, err := p.answerChallenge(ctx, path+"/challenge", privateKey)
+	if err != nil {
+		return err
+	}
+	return p.do(ctx, http.MethodDelete, path+"/"+id, authorization, nil, nil)
+}
+
+func inboxPath(privateKey []byte) (string, error) {
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return "", err
+	}
+	return "/inbox/" + base64.RawURLEncoding.EncodeToString(publicKey), nil
+}
+
+// answerChallenge fetches a challenge from path and returns the
+// Authorization header answering it: the token sealed with AES-GCM under a
+// key agreed between privateKey and the challenge's ephemeral key.
+func (p *Point) answerChallenge(ctx context.Context, path string, privateKey []byte) (string, error) {
+	var challenge types.InboxChallengeResponse
+	if err := p.do(ctx, http.MethodGet, path, "", nil, &challenge); err != nil {
+		return "", err
+	}
+	token, err := base64.StdEncoding.DecodeString(challenge.Token)
+	if err != nil {
+		return "", fmt.Errorf("invalid challenge token: %w", err)
+	}
+	serverKey, err := base64.StdEncoding.DecodeString(challenge.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("invalid challenge key: %w", err)
+	}
+
+	sharedSecret, err := curve25519.X25519(privateKey, serverKey)
+	if err != nil {
+		return "", err
+	}
+	key := make([]byte, 32)
+	if _, err := hkdf.New(sha256.New, sharedSecret, nil, nil).Read(key); err != nil {
+		return "", err
+	}
+	encryptedToken, err := seal(key, token)
+	if err != nil {
+		return "", err
+	}
+
+	auth, err := json.Marshal(types.ChallengeAuth{
+		Nonce:          challenge.Nonce,
+		EncryptedToken: base64.StdEncoding.EncodeToString(encryptedToken),
+	})
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + base64.StdEncoding.EncodeToString(auth), nil
+}
+
+func (p *Point) do(ctx context.Context, method string, path string, authorization string, body any, out any) error {
+	return p.doURL(ctx, method, p.URL+"/v1"+path, authorization, body, out)
+}
+
+func (p *Point) doURL(ctx context.Context, method string, url string, authorization string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range p.Header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{Status: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr.ErrorResponse); err != nil {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	return nil
+}
+
+// NewPrivateKey returns a random Curve25519 private key for a recipient.
+func NewPrivateKey() ([]byte, error) {
+	key := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/orgs"
+	"github.com/berkmancenter/rendezvous-point/router"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loopbackResolver places every test connection in one organization.
+var loopbackResolver = orgs.ResolverFunc(func(addr netip.Addr) (*orgs.Organization, error) {
+	return &orgs.Organization{Name: "Example Corp", Prefix: netip.PrefixFrom(addr, addr.BitLen())}, nil
+})
+
+type testPoint struct {
+	*Point
+	store store.Store
+}
+
+func setupTestPoints(t *testing.T, n int) []testPoint {
+	var points []testPoint
+	for range n {
+		s := store.NewMemory()
+		e := echo.New()
+		router.RegisterRoutes(e, router.Config{
+			Store:      s,
+			Resolver:   loopbackResolver,
+			Thresholds: router.Thresholds{Default: 1},
+		})
+		server := httptest.NewServer(e)
+		t.Cleanup(server.Close)
+		points = append(points, testPoint{NewPoint(server.URL), s})
+	}
+	return points
+}
+
+func approve(t *testing.T, s store.Store, publicKey string) {
+	r, err := s.Recipient(publicKey)
+	require.NoError(t, err)
+	r.Status = types.RecipientApproved
+	require.NoError(t, s.PutRecipient(*r))
+}
+
+func TestDisclosureFlow(t *testing.T) {
+	ctx := context.Background()
+	testPoints := setupTestPoints(t, 3)
+	var points Points
+	for _, p := range testPoints {
+		points = append(points, p.Point)
+	}
+
+	// The recipient registers everywhere and is listed once approved.
+	privateKey, publicKey := newTestKey(t)
+	require.NoError(t, points.Register(ctx, privateKey, "Alice"))
+	recipients, err := points.CommonRecipients(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, recipients)
+
+	for _, p := range testPoints {
+		approve(t, p.store, base64.StdEncoding.EncodeToString(publicKey))
+	}
+	recipients, err = points.CommonRecipients(ctx)
+	require.NoError(t, err)
+	require.Len(t, recipients, 1)
+	assert.Equal(t, "Alice", recipients[0].Name)
+
+	// A whistleblower discloses to them across all three points.
+	credentials, err := points.Credentials(ctx)
+	require.NoError(t, err)
+	require.Len(t, credentials, 3)
+	organization, err := CommonOrganization(credentials)
+	require.NoError(t, err)
+	assert.Equal(t, "Example Corp", organization)
+
+	d, err := NewDisclosure("The password is swordfish", "nora")
+	require.NoError(t, err)
+	require.NoError(t, points.SubmitDisclosure(ctx, credentials, publicKey, d))
+
+	// The recipient opens it, which deletes it.
+	disclosures, err := points.CheckInbox(ctx, privateKey)
+	require.NoError(t, err)
+	require.Len(t, disclosures, 1)
+	assert.Equal(t, d.ID, disclosures[0].ID)
+	assert.Equal(t, d.Text, disclosures[0].Text)
+	assert.Equal(t, "Example Corp", disclosures[0].Organization)
+
+	for _, p := range points {
+		shares, err := p.Inbox(ctx, privateKey)
+		require.NoError(t, err)
+		assert.Empty(t, shares)
+	}
+}
+
+func TestCheckInbox_ToleratesOneBadPoint(t *testing.T) {
+	ctx := context.Background()
+	testPoints := setupTestPoints(t, 3)
+	var points Points
+	for _, p := range testPoints {
+		points = append(points, p.Point)
+	}
+
+	privateKey, publicKey := newTestKey(t)
+	require.NoError(t, points.Register(ctx, privateKey, "Alice"))
+	credentials, err := points.Credentials(ctx)
+	require.NoError(t, err)
+	d, err := NewDisclosure("text", "author")
+	require.NoError(t, err)
+	require.NoError(t, points.SubmitDisclosure(ctx, credentials, publicKey, d))
+
+	// One point alters the share it holds. Two honest shares of three
+	// still recover the disclosure.
+	shares, err := testPoints[0].store.Shares(publicKey)
+	require.NoError(t, err)
+	require.Len(t, shares, 1)
+	shares[0].VerifiableShare.Data = "AQID"
+	require.NoError(t, testPoints[0].store.PutShare(publicKey, shares[0]))
+
+	pending, err := points.Pending(ctx, privateKey)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, d.ID, pending[0].ID)
+	assert.Len(t, pending[0].Shares, 2)
+	assert.Equal(t, 1, pending[0].Rejected)
+
+	disclosures, err := points.CheckInbox(ctx, privateKey)
+	require.NoError(t, err)
+	require.Len(t, disclosures, 1)
+	assert.Equal(t, "text", disclosures[0].Text)
+}
+
+func TestPointErrors(t *testing.T) {
+	ctx := context.Background()
+	p := setupTestPoints(t, 1)[0]
+
+	privateKey, _ := newTestKey(t)
+	_, err := p.Inbox(ctx, privateKey)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+	assert.Equal(t, types.ErrorNotRegistered, apiErr.Code)
+
+	info, err := p.Info(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, info.Protocols, Protocol)
+
+	// Credentials naming different organizations are not combined.
+	mismatched := []*Credential{{Organization: "A"}, {Organization: "B"}}
+	assert.ErrorIs(t, Points{p.Point}.SubmitDisclosure(ctx, mismatched, nil, &Disclosure{}), ErrOrganizationMismatch)
+
+	// Nor is a disclosure sent without a credential from every point.
+	other := NewPoint(p.URL)
+	credential, err := p.Credential(ctx)
+	require.NoError(t, err)
+	err = Points{p.Point, other}.SubmitDisclosure(ctx, []*Credential{credential}, nil, &Disclosure{})
+	assert.ErrorIs(t, err, ErrMissingCredential)
+}
+
+func TestPointHeader(t *testing.T) {
+	// A point taking the remote address from a header, as one run with
+	// -remote-ip-header does.
+	e := echo.New()
+	e.IPExtractor = func(req *http.Request) string { return req.Header.Get("X-Remote-IP") }
+	router.RegisterRoutes(e, router.Config{
+		Resolver: orgs.ResolverFunc(func(addr netip.Addr) (*orgs.Organization, error) {
+			return &orgs.Organization{Name: "Org " + addr.String(), Prefix: netip.PrefixFrom(addr, addr.BitLen())}, nil
+		}),
+	})
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	for _, ip := range []string{"192.0.2.1", "198.51.100.1"} {
+		p := &Point{URL: server.URL, Header: http.Header{"X-Remote-IP": {ip}}}
+		credential, err := p.Credential(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "Org "+ip, credential.Organization)
+	}
+}
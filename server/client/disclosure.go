@@ -0,0 +1,250 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/berkmancenter/rendezvous-point/shamir"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+var (
+	ErrInvalidID         = errors.New("invalid disclosure id")
+	ErrInvalidCommitment = errors.New("share does not match its commitment")
+)
+
+// Disclosure is what a whistleblower writes. It is encoded as the iOS app
+// encodes it, so either can read the other's.
+type Disclosure struct {
+	// ID is an upper case UUID, as Swift formats them.
+	ID     string `json:"id"`
+	Text   string `json:"text"`
+	Author string `json:"author"`
+	// Organization is filled in by the recipient from the inbox the shares
+	// arrived in. The whistleblower leaves it empty.
+	Organization string `json:"organization,omitempty"`
+}
+
+// NewDisclosure returns a disclosure with a random ID.
+func NewDisclosure(text string, author string) (*Disclosure, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	// Version 4, RFC 4122 variant.
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+
+	return &Disclosure{ID: formatUUID(id), Text: text, Author: author}, nil
+}
+
+// RecoveryThreshold is how many of n rendezvous points' shares recover a
+// disclosure: two thirds, rounded up.
+func RecoveryThreshold(n int) int {
+	return (2*n + 2) / 3
+}
+
+// Shares encrypts the disclosure to recipient, a Curve25519 public key, and
+// splits the ciphertext into n shares, any threshold of which recover it.
+// Every share carries the same ephemeral key and its own commitment.
+func (d *Disclosure) Shares(recipient []byte, n int, threshold int) ([]types.VerifiableShare, error) {
+	id, err := parseUUID(d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralKey := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(ephemeralKey); err != nil {
+		return nil, err
+	}
+	ephemeralPublicKey, err := curve25519.X25519(ephemeralKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	key, err := symmetricKey(ephemeralKey, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	split, err := shamir.Split(ciphertext, n, threshold)
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]types.VerifiableShare, len(split))
+	for i, share := range split {
+		shares[i] = types.VerifiableShare{
+			Data:         base64.StdEncoding.EncodeToString(share),
+			EphemeralKey: base64.StdEncoding.EncodeToString(ephemeralPublicKey),
+			Commitment:   base64.StdEncoding.EncodeToString(commitment(key, id, share)),
+		}
+	}
+	return shares, nil
+}
+
+// Verify checks that share, delivered for the disclosure id, is what the
+// whistleblower committed to. A rendezvous point cannot forge a share that
+// passes without the recipient's private key.
+func Verify(id string, share types.VerifiableShare, privateKey []byte) error {
+	uuid, err := parseUUID(id)
+	if err != nil {
+		return err
+	}
+	data, ephemeralKey, claimed, err := decodeShare(share)
+	if err != nil {
+		return err
+	}
+	key, err := symmetricKey(privateKey, ephemeralKey)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(commitment(key, uuid, data), claimed) {
+		return ErrInvalidCommitment
+	}
+	return nil
+}
+
+// Open combines shares of one disclosure and decrypts it with the
+// recipient's private key. It fails if there are too few shares, or any
+// were altered, since the combined ciphertext then does not authenticate.
+func Open(shares []types.VerifiableShare, privateKey []byte) (*Disclosure, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("%w: no shares", shamir.ErrInvalidShares)
+	}
+
+	split := make([][]byte, len(shares))
+	var ephemeralKey []byte
+	for i, share := range shares {
+		data, key, _, err := decodeShare(share)
+		if err != nil {
+			return nil, err
+		}
+		split[i] = data
+		ephemeralKey = key
+	}
+	ciphertext, err := shamir.Combine(split)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := symmetricKey(privateKey, ephemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(key, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	var d Disclosure
+	if err := json.Unmarshal(plaintext, &d); err != nil {
+		return nil, fmt.Errorf("invalid disclosure: %w", err)
+	}
+	return &d, nil
+}
+
+func decodeShare(share types.VerifiableShare) (data []byte, ephemeralKey []byte, commitment []byte, err error) {
+	if data, err = base64.StdEncoding.DecodeString(share.Data); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid share data: %w", err)
+	}
+	ephemeralKey, err = base64.StdEncoding.DecodeString(share.EphemeralKey)
+	if err != nil || len(ephemeralKey) != curve25519.PointSize {
+		return nil, nil, nil, errors.New("invalid ephemeral key")
+	}
+	if commitment, err = base64.StdEncoding.DecodeString(share.Commitment); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid commitment: %w", err)
+	}
+	return data, ephemeralKey, commitment, nil
+}
+
+// symmetricKey derives the disclosure key from an X25519 exchange, as
+// CryptoKit's hkdfDerivedSymmetricKey does with an empty salt.
+func symmetricKey(privateKey []byte, publicKey []byte) ([]byte, error) {
+	sharedSecret, err := curve25519.X25519(privateKey, publicKey)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := hkdf.New(sha256.New, sharedSecret, nil, []byte("disclosure-encryption")).Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// commitment is HMAC-SHA256 under the disclosure key over the ID's 16 bytes
+// and the share.
+func commitment(key []byte, id []byte, share []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(id)
+	mac.Write(share)
+	return mac.Sum(nil)
+}
+
+// seal encrypts with AES-GCM, returning nonce, ciphertext and tag together
+// as CryptoKit's combined representation does.
+func seal(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key []byte, combined []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(combined) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := combined[:gcm.NonceSize()], combined[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func formatUUID(id []byte) string {
+	s := strings.ToUpper(hex.EncodeToString(id))
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+func parseUUID(s string) ([]byte, error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+	id, err := hex.DecodeString(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidID, s)
+	}
+	return id, nil
+}
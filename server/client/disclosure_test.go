@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/curve25519"
+)
+
+func newTestKey(t *testing.T) (privateKey []byte, publicKey []byte) {
+	privateKey, err := NewPrivateKey()
+	require.NoError(t, err)
+	publicKey, err = curve25519.X25519(privateKey, curve25519.Basepoint)
+	require.NoError(t, err)
+	return privateKey, publicKey
+}
+
+func TestSymmetricKeyAndCommitment_Vector(t *testing.T) {
+	// RFC 7748, section 6.1.
+	alice, _ := hex.DecodeString("77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a")
+	bob, _ := hex.DecodeString("de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f")
+
+	key, err := symmetricKey(alice, bob)
+	require.NoError(t, err)
+	assert.Equal(t, "699b169d633093236dd61c9733fa467b881b4aa00d0e14c7834ae4d245e7f9d0", hex.EncodeToString(key))
+
+	id, err := parseUUID("E621E1F8-C36C-495A-93FC-0C247A3E6E5F")
+	require.NoError(t, err)
+	assert.Equal(t, "a8d698388cd0f488923b72e8302c31d32cfaba8831b5cb89660d95a49368c24c", hex.EncodeToString(commitment(key, id, []byte("share"))))
+}
+
+// TestDisclosure_SharedVector opens testdata/disclosure-vector.json, a
+// disclosure split 2 of 3 with fixed keys, nonce and coefficients. The iOS
+// app's CompatibilityTests open the same vector with SwiftySSS and
+// CryptoKit, so the two must change together.
+func TestDisclosure_SharedVector(t *testing.T) {
+	data, err := os.ReadFile("testdata/disclosure-vector.json")
+	require.NoError(t, err)
+	var vector struct {
+		ID                  string                  `json:"id"`
+		Text                string                  `json:"text"`
+		Author              string                  `json:"author"`
+		RecipientPrivateKey []byte                  `json:"recipientPrivateKey"`
+		Shares              []types.VerifiableShare `json:"shares"`
+	}
+	require.NoError(t, json.Unmarshal(data, &vector))
+	require.Len(t, vector.Shares, 3)
+
+	for _, share := range vector.Shares {
+		assert.NoError(t, Verify(vector.ID, share, vector.RecipientPrivateKey))
+	}
+	for _, pair := range [][2]int{{0, 1}, {0, 2}, {2, 1}} {
+		d, err := Open([]types.VerifiableShare{vector.Shares[pair[0]], vector.Shares[pair[1]]}, vector.RecipientPrivateKey)
+		require.NoError(t, err)
+		assert.Equal(t, Disclosure{ID: vector.ID, Text: vector.Text, Author: vector.Author}, *d)
+	}
+}
+
+func TestNewDisclosure(t *testing.T) {
+	d, err := NewDisclosure("text", "author")
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9A-F]{8}-[0-9A-F]{4}-4[0-9A-F]{3}-[89AB][0-9A-F]{3}-[0-9A-F]{12}$`), d.ID)
+
+	id, err := parseUUID(d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, d.ID, formatUUID(id))
+
+	_, err = parseUUID("not-a-uuid")
+	assert.ErrorIs(t, err, ErrInvalidID)
+}
+
+func TestSharesRoundTrip(t *testing.T) {
+	privateKey, publicKey := newTestKey(t)
+	d, err := NewDisclosure("The password is swordfish", "nora")
+	require.NoError(t, err)
+
+	shares, err := d.Shares(publicKey, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+	for _, share := range shares {
+		assert.NoError(t, Verify(d.ID, share, privateKey))
+		assert.Equal(t, shares[0].EphemeralKey, share.EphemeralKey)
+	}
+
+	opened, err := Open([]types.VerifiableShare{shares[4], shares[1], shares[2]}, privateKey)
+	require.NoError(t, err)
+	assert.Equal(t, d, opened)
+
+	// Too few shares do not authenticate.
+	_, err = Open(shares[:2], privateKey)
+	assert.Error(t, err)
+
+	// Nor does the wrong key.
+	other, _ := newTestKey(t)
+	_, err = Open(shares[:3], other)
+	assert.Error(t, err)
+	assert.ErrorIs(t, Verify(d.ID, shares[0], other), ErrInvalidCommitment)
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	privateKey, publicKey := newTestKey(t)
+	d, err := NewDisclosure("text", "author")
+	require.NoError(t, err)
+	shares, err := d.Shares(publicKey, 3, 2)
+	require.NoError(t, err)
+
+	data, _ := base64.StdEncoding.DecodeString(shares[0].Data)
+	data[1] ^= 1
+	tampered := shares[0]
+	tampered.Data = base64.StdEncoding.EncodeToString(data)
+	assert.ErrorIs(t, Verify(d.ID, tampered, privateKey), ErrInvalidCommitment)
+
+	// A share moved to another disclosure fails too.
+	other, err := NewDisclosure("text", "author")
+	require.NoError(t, err)
+	assert.ErrorIs(t, Verify(other.ID, shares[0], privateKey), ErrInvalidCommitment)
+}
+
+func TestRecoveryThreshold(t *testing.T) {
+	for n, threshold := range map[int]int{1: 1, 2: 2, 3: 2, 4: 3, 5: 4, 6: 4} {
+		assert.Equal(t, threshold, RecoveryThreshold(n), "n=%d", n)
+	}
+}
@@ -0,0 +1,236 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+)
+
+// ErrOrganizationMismatch is returned when credentials from different
+// points name different organizations, so their shares would never be
+// counted together.
+var ErrOrganizationMismatch = errors.New("credentials name different organizations")
+
+// ErrMissingCredential is returned when a disclosure is submitted without a
+// credential from one of the points.
+var ErrMissingCredential = errors.New("no credential from this point")
+
+// Points are the rendezvous points a disclosure is split across, one share
+// each.
+type Points []*Point
+
+// Credentials requests a credential from every point. It returns those
+// issued along with the errors from the points that failed.
+func (ps Points) Credentials(ctx context.Context) ([]*Credential, error) {
+	credentials := make([]*Credential, len(ps))
+	errs := ps.each(func(i int, p *Point) error {
+		credential, err := p.Credential(ctx)
+		credentials[i] = credential
+		return err
+	})
+
+	issued := make([]*Credential, 0, len(ps))
+	for _, credential := range credentials {
+		if credential != nil {
+			issued = append(issued, credential)
+		}
+	}
+	return issued, errs
+}
+
+// CommonOrganization returns the organization every credential names.
+func CommonOrganization(credentials []*Credential) (string, error) {
+	if len(credentials) == 0 {
+		return "", errors.New("no credentials")
+	}
+	for _, credential := range credentials[1:] {
+		if credential.Organization != credentials[0].Organization {
+			return "", ErrOrganizationMismatch
+		}
+	}
+	return credentials[0].Organization, nil
+}
+
+// SubmitDisclosure encrypts d to recipient and sends one share to each
+// point, using that point's credential. It needs a credential from every
+// point: the shares are split so that two thirds of all the points recover
+// them, as CheckInbox expects, and a point left out would count against
+// that threshold.
+func (ps Points) SubmitDisclosure(ctx context.Context, credentials []*Credential, recipient []byte, d *Disclosure) error {
+	if _, err := CommonOrganization(credentials); err != nil {
+		return err
+	}
+	ordered := make([]*Credential, len(ps))
+	for i, p := range ps {
+		for _, credential := range credentials {
+			if credential.Point == p {
+				ordered[i] = credential
+			}
+		}
+		if ordered[i] == nil {
+			return fmt.Errorf("%s: %w", p.URL, ErrMissingCredential)
+		}
+	}
+	if len(credentials) != len(ps) {
+		return fmt.Errorf("%d credentials for %d points", len(credentials), len(ps))
+	}
+
+	shares, err := d.Shares(recipient, len(ps), RecoveryThreshold(len(ps)))
+	if err != nil {
+		return err
+	}
+	return ps.each(func(i int, p *Point) error {
+		return p.Disclose(ctx, ordered[i], recipient, d.ID, shares[i])
+	})
+}
+
+// Register registers the recipient holding privateKey at every point.
+func (ps Points) Register(ctx context.Context, privateKey []byte, name string) error {
+	return ps.each(func(_ int, p *Point) error {
+		return p.Register(ctx, privateKey, name)
+	})
+}
+
+// CommonRecipients returns the approved recipients every point lists, as
+// the first point lists them. Each point's directory signature is checked.
+func (ps Points) CommonRecipients(ctx context.Context) ([]types.Recipient, error) {
+	lists := make([][]types.Recipient, len(ps))
+	err := ps.each(func(i int, p *Point) error {
+		claims, err := p.Directory(ctx)
+		if err != nil {
+			return err
+		}
+		lists[i] = claims.Recipients
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, nil
+	}
+
+	counts := make(map[string]int)
+	for _, list := range lists {
+		for _, r := range list {
+			counts[r.PublicKey]++
+		}
+	}
+	var common []types.Recipient
+	for _, r := range lists[0] {
+		if counts[r.PublicKey] == len(lists) {
+			common = append(common, r)
+		}
+	}
+	return common, nil
+}
+
+// Pending is one disclosure's shares as released to a recipient, gathered
+// from every point.
+type Pending struct {
+	ID           string
+	Organization string
+	// Shares passed their commitments.
+	Shares []types.VerifiableShare
+	// Rejected counts shares that failed their commitments, which means a
+	// point altered them.
+	Rejected int
+}
+
+// Pending collects the shares released to the recipient holding
+// privateKey from every point, grouped by disclosure in the order first
+// seen. Points that fail are reported in the error alongside the shares
+// gathered from the rest.
+func (ps Points) Pending(ctx context.Context, privateKey []byte) ([]*Pending, error) {
+	type key struct{ org, id string }
+	var mu sync.Mutex
+	grouped := make(map[key]*Pending)
+	var pending []*Pending
+
+	err := ps.each(func(_ int, p *Point) error {
+		shares, err := p.Inbox(ctx, privateKey)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, share := range shares {
+			k := key{share.Org, share.ID}
+			group, ok := grouped[k]
+			if !ok {
+				group = &Pending{ID: share.ID, Organization: share.Org}
+				grouped[k] = group
+				pending = append(pending, group)
+			}
+			if Verify(share.ID, share.VerifiableShare, privateKey) != nil {
+				group.Rejected++
+				continue
+			}
+			group.Shares = append(group.Shares, share.VerifiableShare)
+		}
+		return nil
+	})
+	return pending, err
+}
+
+// Open decrypts the disclosure, attributed to the organization its shares
+// were released under.
+func (p *Pending) Open(privateKey []byte) (*Disclosure, error) {
+	d, err := Open(p.Shares, privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("disclosure %s: %w", p.ID, err)
+	}
+	d.Organization = p.Organization
+	return d, nil
+}
+
+// DeleteShares deletes the shares of disclosure id from the recipient's
+// inbox at every point.
+func (ps Points) DeleteShares(ctx context.Context, privateKey []byte, id string) error {
+	return ps.each(func(_ int, p *Point) error {
+		return p.DeleteShare(ctx, privateKey, id)
+	})
+}
+
+// CheckInbox opens every pending disclosure with enough shares and
+// deletes it from every point, returning the disclosures opened along with
+// any errors.
+func (ps Points) CheckInbox(ctx context.Context, privateKey []byte) ([]Disclosure, error) {
+	pending, errs := ps.Pending(ctx, privateKey)
+
+	var disclosures []Disclosure
+	for _, p := range pending {
+		if len(p.Shares) < RecoveryThreshold(len(ps)) {
+			continue
+		}
+		d, err := p.Open(privateKey)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		disclosures = append(disclosures, *d)
+		errs = errors.Join(errs, ps.DeleteShares(ctx, privateKey, p.ID))
+	}
+	return disclosures, errs
+}
+
+// each calls f for every point concurrently, joining their errors with the
+// point's URL.
+func (ps Points) each(f func(i int, p *Point) error) error {
+	errs := make([]error, len(ps))
+	var wg sync.WaitGroup
+	for i, p := range ps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(i, p); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", p.URL, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
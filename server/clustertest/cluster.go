@@ -0,0 +1,183 @@
+// Package clustertest runs several rendezvous points in process, each with
+// its own store and keys, to test what only holds across points: that a
+// disclosure split between them is recovered once enough of them release
+// their shares, and not before.
+package clustertest
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/client"
+	"github.com/berkmancenter/rendezvous-point/orgs"
+	"github.com/berkmancenter/rendezvous-point/router"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/curve25519"
+)
+
+// IPHeader carries the address a request is treated as coming from, so
+// one test can act as whistleblowers in many organizations.
+const IPHeader = "X-Remote-IP"
+
+// Organizations maps the networks the fake resolver knows to their
+// organizations.
+var Organizations = map[netip.Prefix]string{
+	netip.MustParsePrefix("10.1.0.0/16"): "Acme Corp",
+	netip.MustParsePrefix("10.2.0.0/16"): "Globex",
+	netip.MustParsePrefix("10.3.0.0/16"): "Initech",
+}
+
+// Resolver resolves the addresses in Organizations, and nothing else.
+var Resolver = orgs.ResolverFunc(func(addr netip.Addr) (*orgs.Organization, error) {
+	for prefix, name := range Organizations {
+		if prefix.Contains(addr) {
+			return &orgs.Organization{Name: name, Prefix: prefix}, nil
+		}
+	}
+	return nil, orgs.ErrNotFound
+})
+
+// Options configure a cluster.
+type Options struct {
+	// Points is how many rendezvous points to run. Defaults to 3.
+	Points int
+	// Configure adjusts the configuration of the i-th point before it
+	// starts. Each point has a fresh store and keys and uses Resolver.
+	Configure func(i int, cfg *router.Config)
+}
+
+// Node is one rendezvous point in a cluster.
+type Node struct {
+	Server *httptest.Server
+	Store  store.Store
+	Point  *client.Point
+}
+
+// Kill stops the node. Requests to it fail from then on.
+func (n *Node) Kill() {
+	n.Server.Close()
+}
+
+// Corrupt rewrites every share the node holds for recipient with f, as a
+// malicious or faulty point might.
+func (n *Node) Corrupt(t testing.TB, recipient []byte, f func(*store.Share)) {
+	t.Helper()
+	shares, err := n.Store.Shares(recipient)
+	require.NoError(t, err)
+	for _, share := range shares {
+		f(&share)
+		require.NoError(t, n.Store.PutShare(recipient, share))
+	}
+}
+
+// Cluster is a set of independent rendezvous points.
+type Cluster struct {
+	Nodes []*Node
+}
+
+// New starts a cluster, closing it when the test ends.
+func New(t testing.TB, opts Options) *Cluster {
+	t.Helper()
+	if opts.Points == 0 {
+		opts.Points = 3
+	}
+
+	c := &Cluster{}
+	for i := range opts.Points {
+		s := store.NewMemory()
+		cfg := router.Config{Store: s, Resolver: Resolver}
+		if opts.Configure != nil {
+			opts.Configure(i, &cfg)
+		}
+
+		e := echo.New()
+		direct := echo.ExtractIPDirect()
+		e.IPExtractor = func(req *http.Request) string {
+			if ip := req.Header.Get(IPHeader); ip != "" {
+				return ip
+			}
+			return direct(req)
+		}
+		router.RegisterRoutes(e, cfg)
+
+		server := httptest.NewServer(e)
+		t.Cleanup(server.Close)
+		c.Nodes = append(c.Nodes, &Node{Server: server, Store: cfg.Store, Point: client.NewPoint(server.URL)})
+	}
+	return c
+}
+
+// Points returns every node's point, including killed ones.
+func (c *Cluster) Points() client.Points {
+	points := make(client.Points, len(c.Nodes))
+	for i, n := range c.Nodes {
+		points[i] = n.Point
+	}
+	return points
+}
+
+// As returns every node's point, with requests coming from ip.
+func (c *Cluster) As(ip string) client.Points {
+	points := make(client.Points, len(c.Nodes))
+	for i, n := range c.Nodes {
+		points[i] = &client.Point{URL: n.Point.URL, Header: http.Header{IPHeader: {ip}}}
+	}
+	return points
+}
+
+// Recipient is a registered recipient's key pair.
+type Recipient struct {
+	Name       string
+	PrivateKey []byte
+	PublicKey  []byte
+}
+
+// Recipient registers a new recipient at every node and approves it.
+func (c *Cluster) Recipient(t testing.TB, name string) *Recipient {
+	t.Helper()
+	privateKey, err := client.NewPrivateKey()
+	require.NoError(t, err)
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	require.NoError(t, err)
+	require.NoError(t, c.Points().Register(context.Background(), privateKey, name))
+
+	for _, n := range c.Nodes {
+		r, err := n.Store.Recipient(base64.StdEncoding.EncodeToString(publicKey))
+		require.NoError(t, err)
+		r.Status = types.RecipientApproved
+		require.NoError(t, n.Store.PutRecipient(*r))
+	}
+	return &Recipient{Name: name, PrivateKey: privateKey, PublicKey: publicKey}
+}
+
+// Submit discloses text to r as a whistleblower at ip, with a share at
+// every node.
+func (c *Cluster) Submit(t testing.TB, ip string, r *Recipient, text string) *client.Disclosure {
+	t.Helper()
+	ctx := context.Background()
+	points := c.As(ip)
+	credentials, err := points.Credentials(ctx)
+	require.NoError(t, err)
+	d, err := client.NewDisclosure(text, ip)
+	require.NoError(t, err)
+	require.NoError(t, points.SubmitDisclosure(ctx, credentials, r.PublicKey, d))
+	return d
+}
+
+// Fetch opens every disclosure r can recover, deleting them, and returns
+// them with any errors from nodes that failed.
+func (c *Cluster) Fetch(r *Recipient) ([]client.Disclosure, error) {
+	return c.Points().CheckInbox(context.Background(), r.PrivateKey)
+}
+
+// Pending returns the shares of each disclosure released to r.
+func (c *Cluster) Pending(r *Recipient) ([]*client.Pending, error) {
+	return c.Points().Pending(context.Background(), r.PrivateKey)
+}
@@ -0,0 +1,193 @@
+package clustertest
+
+import (
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/client"
+	"github.com/berkmancenter/rendezvous-point/router"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withThreshold(threshold int) func(int, *router.Config) {
+	return func(_ int, cfg *router.Config) {
+		cfg.Thresholds = router.Thresholds{Default: threshold}
+	}
+}
+
+func texts(disclosures []client.Disclosure) []string {
+	var texts []string
+	for _, d := range disclosures {
+		texts = append(texts, d.Text)
+	}
+	return texts
+}
+
+func TestReleasedOnlyAtOrganizationThreshold(t *testing.T) {
+	c := New(t, Options{Configure: withThreshold(3)})
+	r := c.Recipient(t, "Example News")
+
+	c.Submit(t, "10.1.0.1", r, "first")
+	c.Submit(t, "10.1.0.2", r, "second")
+	// Other organizations do not count toward Acme's threshold.
+	c.Submit(t, "10.2.0.1", r, "globex")
+	c.Submit(t, "10.3.0.1", r, "initech")
+
+	disclosures, err := c.Fetch(r)
+	require.NoError(t, err)
+	assert.Empty(t, disclosures)
+
+	c.Submit(t, "10.1.0.3", r, "third")
+	disclosures, err = c.Fetch(r)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"first", "second", "third"}, texts(disclosures))
+	for _, d := range disclosures {
+		assert.Equal(t, "Acme Corp", d.Organization)
+	}
+
+	// Fetching deleted them everywhere. The other organizations' shares
+	// are still held back.
+	disclosures, err = c.Fetch(r)
+	require.NoError(t, err)
+	assert.Empty(t, disclosures)
+}
+
+func TestRecoveryNeedsTwoThirdsOfPoints(t *testing.T) {
+	// The first point holds shares back until five Acme whistleblowers
+	// come forward. The others release them at once.
+	c := New(t, Options{Configure: func(i int, cfg *router.Config) {
+		threshold := 1
+		if i == 0 {
+			threshold = 5
+		}
+		cfg.Thresholds = router.Thresholds{Default: threshold}
+	}})
+	r := c.Recipient(t, "Example News")
+	d := c.Submit(t, "10.1.0.1", r, "text")
+
+	// Two points of three release: enough.
+	pending, err := c.Pending(r)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Len(t, pending[0].Shares, 2)
+
+	// One point releasing is not.
+	c.Nodes[1].Kill()
+	pending, err = c.Pending(r)
+	assert.Error(t, err)
+	require.Len(t, pending, 1)
+	assert.Len(t, pending[0].Shares, 1)
+	_, err = pending[0].Open(r.PrivateKey)
+	assert.Error(t, err)
+
+	disclosures, err := c.Fetch(r)
+	assert.Error(t, err)
+	assert.Empty(t, disclosures)
+
+	// Even a recipient ignoring the two-thirds rule cannot decrypt one
+	// share.
+	_, err = client.Open(pending[0].Shares, r.PrivateKey)
+	assert.Error(t, err)
+	assert.Equal(t, d.ID, pending[0].ID)
+}
+
+func TestRecoveryWithPointDown(t *testing.T) {
+	c := New(t, Options{Configure: withThreshold(1)})
+	r := c.Recipient(t, "Example News")
+	c.Submit(t, "10.1.0.1", r, "text")
+
+	c.Nodes[2].Kill()
+	disclosures, err := c.Fetch(r)
+	assert.ErrorContains(t, err, c.Nodes[2].Point.URL)
+	require.Len(t, disclosures, 1)
+	assert.Equal(t, "text", disclosures[0].Text)
+}
+
+func TestSubmissionNeedsEveryPoint(t *testing.T) {
+	c := New(t, Options{Configure: withThreshold(1)})
+	r := c.Recipient(t, "Example News")
+
+	// A whistleblower the resolver cannot place gets no credentials.
+	_, err := c.As("192.0.2.1").Credentials(t.Context())
+	assert.Error(t, err)
+
+	points := c.As("10.1.0.1")
+	c.Nodes[0].Kill()
+	credentials, err := points.Credentials(t.Context())
+	assert.Error(t, err)
+	assert.Len(t, credentials, 2)
+
+	// Two shares would fall short of two thirds of three points, so the
+	// disclosure is refused rather than sent to the two that are up.
+	d, err := client.NewDisclosure("text", "author")
+	require.NoError(t, err)
+	err = points.SubmitDisclosure(t.Context(), credentials, r.PublicKey, d)
+	assert.ErrorIs(t, err, client.ErrMissingCredential)
+	assert.ErrorContains(t, err, c.Nodes[0].Point.URL)
+
+	pending, err := c.Pending(r)
+	assert.Error(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestCorruptPoint(t *testing.T) {
+	c := New(t, Options{Configure: withThreshold(1)})
+	r := c.Recipient(t, "Example News")
+	c.Submit(t, "10.1.0.1", r, "text")
+
+	// One point altering its share is caught by the commitment, and the
+	// other two recover the disclosure.
+	c.Nodes[0].Corrupt(t, r.PublicKey, func(s *store.Share) {
+		s.VerifiableShare.Data = "AQID"
+	})
+	pending, err := c.Pending(r)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Rejected)
+	assert.Len(t, pending[0].Shares, 2)
+
+	// A second point substituting another disclosure's commitment is
+	// caught too, leaving too few shares.
+	other := c.Recipient(t, "Other")
+	c.Submit(t, "10.1.0.2", other, "other")
+	forged, err := c.Nodes[1].Store.Shares(other.PublicKey)
+	require.NoError(t, err)
+	c.Nodes[1].Corrupt(t, r.PublicKey, func(s *store.Share) {
+		s.VerifiableShare = forged[0].VerifiableShare
+	})
+
+	disclosures, err := c.Fetch(r)
+	require.NoError(t, err)
+	assert.Empty(t, disclosures)
+	pending, err = c.Pending(r)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 2, pending[0].Rejected)
+}
+
+func TestPointsAreIsolated(t *testing.T) {
+	c := New(t, Options{Points: 5, Configure: withThreshold(1)})
+	r := c.Recipient(t, "Example News")
+	c.Submit(t, "10.1.0.1", r, "text")
+
+	for _, n := range c.Nodes {
+		shares, err := n.Store.Shares(r.PublicKey)
+		require.NoError(t, err)
+		require.Len(t, shares, 1)
+	}
+	// Each point holds a different share.
+	seen := make(map[string]bool)
+	for _, n := range c.Nodes {
+		shares, _ := n.Store.Shares(r.PublicKey)
+		assert.False(t, seen[shares[0].VerifiableShare.Data])
+		seen[shares[0].VerifiableShare.Data] = true
+	}
+
+	// Four of five are needed.
+	c.Nodes[0].Kill()
+	c.Nodes[1].Kill()
+	disclosures, err := c.Fetch(r)
+	assert.Error(t, err)
+	assert.Empty(t, disclosures)
+}
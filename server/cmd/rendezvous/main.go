@@ -0,0 +1,305 @@
+// Command rendezvous lets a recipient register with a set of rendezvous
+// points and collect the disclosures released to it, from a desktop rather
+// than the iOS app. It can also submit disclosures, to exercise a cluster
+// end to end.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/berkmancenter/rendezvous-point/client"
+	"golang.org/x/crypto/curve25519"
+)
+
+const usage = `usage: rendezvous [-points urls] [-key file] <command>
+
+commands:
+  keygen                 Create a private key in -key and print its public key
+  register -name name    Register, or rename, the key at every point
+  inbox                  List disclosures waiting for the key and how many shares have arrived
+  fetch [-out dir] [-keep]
+                         Write every disclosure with enough shares to dir, then delete it from every point
+  delete <id>            Delete a disclosure's shares from every point without reading it
+  disclose -to recipient -text text [-author name] [-ip-header name -ips ip,...]
+                         Submit a disclosure to a listed recipient, named or by public key.
+                         With -ips, submit one per address, each sent in -ip-header to points
+                         run locally with -remote-ip-header, to act as many whistleblowers in testing
+
+Points default to $RENDEZVOUS_POINTS, a comma separated list of URLs.
+`
+
+func main() {
+	log.SetFlags(0)
+	flags := flag.NewFlagSet("rendezvous", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	pointURLs := flags.String("points", os.Getenv("RENDEZVOUS_POINTS"), "Comma separated rendezvous point URLs")
+	keyFile := flags.String("key", "rendezvous.key", "File holding the recipient's base64 private key")
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	command, args := flags.Arg(0), flags.Args()[1:]
+
+	if command == "keygen" {
+		if err := keygen(*keyFile); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var points client.Points
+	for _, url := range strings.Split(*pointURLs, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			points = append(points, client.NewPoint(url))
+		}
+	}
+	if len(points) == 0 {
+		log.Fatal("no rendezvous points: set -points or $RENDEZVOUS_POINTS")
+	}
+
+	ctx := context.Background()
+	if command == "disclose" {
+		if err := disclose(ctx, points, args); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	privateKey, err := loadKey(*keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := recipient{points: points, privateKey: privateKey}
+
+	switch command {
+	case "register":
+		err = r.register(ctx, args)
+	case "inbox":
+		err = r.inbox(ctx)
+	case "fetch":
+		err = r.fetch(ctx, args)
+	case "delete":
+		err = r.delete(ctx, args)
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// keygen writes a new private key to path, refusing to replace one, and
+// prints the public key to give whistleblowers' directories.
+func keygen(path string) error {
+	privateKey, err := client.NewPrivateKey()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, base64.StdEncoding.EncodeToString(privateKey)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return err
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(publicKey))
+	return nil
+}
+
+func loadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w (create one with keygen)", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != curve25519.ScalarSize {
+		return nil, fmt.Errorf("%s does not hold a base64 Curve25519 private key", path)
+	}
+	return key, nil
+}
+
+type recipient struct {
+	points     client.Points
+	privateKey []byte
+}
+
+func (r recipient) register(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("register", flag.ExitOnError)
+	name := flags.String("name", "", "Name whistleblowers see in the directory")
+	flags.Parse(args)
+	if *name == "" {
+		return errors.New("register needs -name")
+	}
+
+	if err := r.points.Register(ctx, r.privateKey, *name); err != nil {
+		return err
+	}
+	fmt.Printf("registered %q at %d points; it is listed once each operator approves it\n", *name, len(r.points))
+	return nil
+}
+
+func (r recipient) inbox(ctx context.Context) error {
+	pending, err := r.points.Pending(ctx, r.privateKey)
+	if err != nil {
+		// Shares from the points that answered are still worth listing.
+		log.Print(err)
+	}
+
+	needed := client.RecoveryThreshold(len(r.points))
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tORGANIZATION\tSHARES\tREJECTED")
+	for _, p := range pending {
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\n", p.ID, p.Organization, len(p.Shares), needed, p.Rejected)
+	}
+	return w.Flush()
+}
+
+// fetch writes each disclosure that can be opened to its own file before
+// deleting it, so a failed write leaves the shares at the points.
+func (r recipient) fetch(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	out := flags.String("out", "disclosures", "Directory to write disclosures to")
+	keep := flags.Bool("keep", false, "Leave disclosures at the points after writing them")
+	flags.Parse(args)
+
+	pending, errs := r.points.Pending(ctx, r.privateKey)
+	if err := os.MkdirAll(*out, 0o700); err != nil {
+		return err
+	}
+
+	needed := client.RecoveryThreshold(len(r.points))
+	for _, p := range pending {
+		if len(p.Shares) < needed {
+			continue
+		}
+		d, err := p.Open(r.privateKey)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return err
+		}
+		// The ID passed its commitment check, so it is a UUID and safe to
+		// use as a file name.
+		path := filepath.Join(*out, p.ID+".json")
+		if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+			return err
+		}
+		fmt.Println(path)
+
+		if !*keep {
+			errs = errors.Join(errs, r.points.DeleteShares(ctx, r.privateKey, p.ID))
+		}
+	}
+	return errs
+}
+
+func (r recipient) delete(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("delete needs a disclosure id")
+	}
+	return r.points.DeleteShares(ctx, r.privateKey, args[0])
+}
+
+// disclose submits a disclosure as the caller, or with -ips as one
+// whistleblower per address.
+func disclose(ctx context.Context, points client.Points, args []string) error {
+	flags := flag.NewFlagSet("disclose", flag.ExitOnError)
+	to := flags.String("to", "", "Recipient's name in the directory, or public key")
+	text := flags.String("text", "", "Disclosure text")
+	author := flags.String("author", "", "Author to sign the disclosure with")
+	ipHeader := flags.String("ip-header", "X-Remote-IP", "Header the points take the remote IP from in testing")
+	ips := flags.String("ips", "", "Comma separated addresses to submit from, one whistleblower each")
+	flags.Parse(args)
+	if *to == "" || *text == "" {
+		return errors.New("disclose needs -to and -text")
+	}
+
+	recipient, err := findRecipient(ctx, points, *to)
+	if err != nil {
+		return err
+	}
+
+	if *ips == "" {
+		return submit(ctx, points, recipient, *text, *author)
+	}
+	var errs error
+	for _, ip := range strings.Split(*ips, ",") {
+		ip = strings.TrimSpace(ip)
+		as := make(client.Points, len(points))
+		for i, p := range points {
+			as[i] = &client.Point{URL: p.URL, Header: http.Header{*ipHeader: {ip}}}
+		}
+		if err := submit(ctx, as, recipient, *text, *author); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", ip, err))
+		}
+	}
+	return errs
+}
+
+// findRecipient resolves to, a name or public key, against the recipients
+// every point lists.
+func findRecipient(ctx context.Context, points client.Points, to string) ([]byte, error) {
+	recipients, err := points.CommonRecipients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, r := range recipients {
+		if r.Name == to || r.PublicKey == to {
+			matches = append(matches, r.PublicKey)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no recipient %q is listed at every point", to)
+	case 1:
+		return base64.StdEncoding.DecodeString(matches[0])
+	default:
+		return nil, fmt.Errorf("%d recipients are named %q; give a public key", len(matches), to)
+	}
+}
+
+func submit(ctx context.Context, points client.Points, recipient []byte, text string, author string) error {
+	credentials, err := points.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+	organization, err := client.CommonOrganization(credentials)
+	if err != nil {
+		return err
+	}
+	d, err := client.NewDisclosure(text, author)
+	if err != nil {
+		return err
+	}
+	if err := points.SubmitDisclosure(ctx, credentials, recipient, d); err != nil {
+		return err
+	}
+	fmt.Printf("%s\t%s\n", d.ID, organization)
+	return nil
+}
@@ -0,0 +1,118 @@
+// Package directory builds and checks the signed recipient directory a
+// rendezvous point publishes, so that clients and auditors can catch a
+// point showing different recipients to different people.
+package directory
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/types"
+)
+
+var (
+	ErrHashMismatch = errors.New("directory hash does not match its recipients")
+	ErrEquivocation = errors.New("directory equivocates: one epoch, two different recipient lists")
+	ErrRollback     = errors.New("directory epoch went backwards")
+)
+
+// Claims is the signed directory document.
+type Claims struct {
+	// Epoch increases every time the listed recipients change.
+	Epoch uint64 `json:"epoch"`
+	// Hash is the base64 encoded Hash of Recipients.
+	Hash       string            `json:"hash"`
+	Recipients []types.Recipient `json:"recipients"`
+	jwt.RegisteredClaims
+}
+
+// New returns the directory of recipients in epoch, sorted as it is
+// hashed.
+func New(epoch uint64, recipients []types.Recipient) *Claims {
+	recipients = slices.Clone(recipients)
+	Sort(recipients)
+	return &Claims{
+		Epoch:      epoch,
+		Hash:       base64.StdEncoding.EncodeToString(Hash(recipients)),
+		Recipients: recipients,
+	}
+}
+
+// Sort orders recipients by public key.
+func Sort(recipients []types.Recipient) {
+	slices.SortFunc(recipients, func(a, b types.Recipient) int {
+		return strings.Compare(a.PublicKey, b.PublicKey)
+	})
+}
+
+// Hash is the SHA-256 of the recipients sorted by public key. Each
+// contributes its public key, name, organization and contact, each
+// prefixed with its length as a big endian uint32, then its threshold as
+// a big endian uint32. The encoding is simple enough for any client to
+// reproduce.
+func Hash(recipients []types.Recipient) []byte {
+	recipients = slices.Clone(recipients)
+	Sort(recipients)
+
+	h := sha256.New()
+	var buf []byte
+	for _, r := range recipients {
+		buf = buf[:0]
+		for _, field := range []string{r.PublicKey, r.Name, r.Organization, r.Contact} {
+			buf = binary.BigEndian.AppendUint32(buf, uint32(len(field)))
+			buf = append(buf, field...)
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(r.Threshold))
+		h.Write(buf)
+	}
+	return h.Sum(nil)
+}
+
+// Parse verifies a signed directory and that its hash covers its
+// recipients.
+func Parse(signed string, keyFunc jwt.Keyfunc) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(signed, &claims, keyFunc, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Hash != base64.StdEncoding.EncodeToString(Hash(claims.Recipients)) {
+		return nil, ErrHashMismatch
+	}
+	return &claims, nil
+}
+
+// KeyFunc verifies directories against a JWKS, such as a point's
+// /.well-known/log-jwks.json.
+func KeyFunc(jwks types.JWKS) jwt.Keyfunc {
+	return func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		for _, jwk := range jwks.Keys {
+			if jwk.KeyID == kid {
+				return keyring.PublicKey(jwk)
+			}
+		}
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+}
+
+// Check compares two directories signed by the same rendezvous point, in
+// the order they were received. Together, two signed directories that fail
+// the check prove the point equivocated.
+func Check(earlier *Claims, later *Claims) error {
+	switch {
+	case later.Epoch < earlier.Epoch:
+		return ErrRollback
+	case later.Epoch == earlier.Epoch && later.Hash != earlier.Hash:
+		return ErrEquivocation
+	}
+	return nil
+}
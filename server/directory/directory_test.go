@@ -0,0 +1,79 @@
+package directory
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRecipients = []types.Recipient{
+	{Name: "Legal Team", PublicKey: "key-b", Organization: "Example News", Threshold: 4},
+	{Name: "Newsroom", PublicKey: "key-a", Contact: "tips@example.org"},
+}
+
+func TestHash(t *testing.T) {
+	// Computed independently, so other clients can check their encoding
+	// against it.
+	assert.Equal(t,
+		"9ae64a018a9d40e02927273a528211a9102c2584202ba30e144afda511705263",
+		hex.EncodeToString(Hash(testRecipients)))
+
+	reversed := []types.Recipient{testRecipients[1], testRecipients[0]}
+	assert.Equal(t, Hash(testRecipients), Hash(reversed), "order must not matter")
+
+	// Field boundaries are unambiguous.
+	assert.NotEqual(t,
+		Hash([]types.Recipient{{PublicKey: "key", Name: "ab", Organization: "c"}}),
+		Hash([]types.Recipient{{PublicKey: "key", Name: "a", Organization: "bc"}}))
+
+	renamed := []types.Recipient{testRecipients[0], testRecipients[1]}
+	renamed[0].Name = "Impostor"
+	assert.NotEqual(t, Hash(testRecipients), Hash(renamed))
+}
+
+func TestParse(t *testing.T) {
+	keys, err := keyring.New(keyring.Options{})
+	require.NoError(t, err)
+
+	claims := New(7, testRecipients)
+	assert.Equal(t, "key-a", claims.Recipients[0].PublicKey)
+	assert.Equal(t, "key-b", testRecipients[0].PublicKey, "input must not be reordered")
+
+	signed, err := keys.Sign(claims)
+	require.NoError(t, err)
+
+	parsed, err := Parse(signed, KeyFunc(keys.JWKS()))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), parsed.Epoch)
+	assert.Equal(t, claims.Recipients, parsed.Recipients)
+
+	// A directory whose recipients do not match its hash is rejected.
+	tampered := New(7, testRecipients)
+	tampered.Recipients = tampered.Recipients[:1]
+	signed, err = keys.Sign(tampered)
+	require.NoError(t, err)
+	_, err = Parse(signed, KeyFunc(keys.JWKS()))
+	assert.ErrorIs(t, err, ErrHashMismatch)
+
+	// So is one signed by another key.
+	other, _ := keyring.New(keyring.Options{})
+	signed, err = other.Sign(claims)
+	require.NoError(t, err)
+	_, err = Parse(signed, KeyFunc(keys.JWKS()))
+	assert.ErrorContains(t, err, "unknown signing key")
+}
+
+func TestCheck(t *testing.T) {
+	first := New(1, testRecipients)
+	second := New(2, testRecipients[:1])
+	forked := New(2, testRecipients[1:])
+
+	assert.NoError(t, Check(first, first))
+	assert.NoError(t, Check(first, second))
+	assert.ErrorIs(t, Check(second, first), ErrRollback)
+	assert.ErrorIs(t, Check(second, forked), ErrEquivocation)
+}
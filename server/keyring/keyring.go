@@ -0,0 +1,398 @@
+package keyring
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptoRand "crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+)
+
+const (
+	pemType          = "EC PRIVATE KEY"
+	pemCreatedHeader = "Created"
+)
+
+// Key is an ES256 signing key identified by its RFC 7638 thumbprint.
+type Key struct {
+	ID         string
+	PrivateKey *ecdsa.PrivateKey
+	CreatedAt  time.Time
+}
+
+type Options struct {
+	// Dir persists keys as PEM files so they survive restarts. Keys are
+	// only held in memory when empty.
+	Dir string
+	// RotateEvery is how long a key signs new credentials before a new
+	// one replaces it. Zero disables rotation.
+	RotateEvery time.Duration
+	// VerifyFor is how long a replaced key keeps verifying, which should
+	// be at least the lifetime of the credentials it signed.
+	VerifyFor time.Duration
+	// Now overrides the clock, for tests.
+	Now func() time.Time
+}
+
+// Keyring holds the current signing key and the retired keys that still
+// verify credentials issued before a rotation.
+type Keyring struct {
+	mu   sync.Mutex
+	opts Options
+	keys []*Key // oldest first; the last key is current
+}
+
+// New loads the keys persisted in opts.Dir, if any. A key is generated on
+// first use when none exist.
+func New(opts Options) (*Keyring, error) {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	k := &Keyring{opts: opts}
+	if opts.Dir == "" {
+		return k, nil
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(opts.Dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		key, err := readKey(path)
+		if err != nil {
+			return nil, err
+		}
+		k.keys = append(k.keys, key)
+	}
+	sort.Slice(k.keys, func(i, j int) bool {
+		return k.keys[i].CreatedAt.Before(k.keys[j].CreatedAt)
+	})
+
+	return k, nil
+}
+
+// NewStatic returns a keyring that always signs with privateKey and never
+// rotates, for keys provisioned out of band.
+func NewStatic(privateKey *ecdsa.PrivateKey) (*Keyring, error) {
+	key, err := newKey(privateKey, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &Keyring{opts: Options{Now: time.Now}, keys: []*Key{key}}, nil
+}
+
+// NewStaticFile returns a keyring that always signs with the PEM encoded key
+// at path, generating and saving a key there first if there is none.
+func NewStaticFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		privateKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptoRand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key, err := newKey(privateKey, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if err := writeKey(path, key); err != nil {
+			return nil, err
+		}
+		return &Keyring{opts: Options{Now: time.Now}, keys: []*Key{key}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewStatic(privateKey)
+}
+
+// ParsePrivateKey decodes a PEM encoded P-256 private key.
+func ParsePrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var (
+		privateKey *ecdsa.PrivateKey
+		err        error
+	)
+	switch block.Type {
+	case pemType:
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		var parsed any
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			var ok bool
+			if privateKey, ok = parsed.(*ecdsa.PrivateKey); !ok {
+				return nil, fmt.Errorf("signing key is not an ECDSA key")
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if privateKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("signing key must use P-256")
+	}
+	return privateKey, nil
+}
+
+// Current returns the key new credentials are signed with, rotating and
+// pruning expired keys first if due.
+func (k *Keyring) Current() (*Key, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.opts.Now()
+	if len(k.keys) == 0 || (k.opts.RotateEvery > 0 && now.Sub(k.current().CreatedAt) >= k.opts.RotateEvery) {
+		if err := k.rotate(now); err != nil {
+			return nil, err
+		}
+	}
+	k.prune(now)
+
+	return k.current(), nil
+}
+
+// Rotate replaces the current signing key immediately.
+func (k *Keyring) Rotate() (*Key, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := k.opts.Now()
+	if err := k.rotate(now); err != nil {
+		return nil, err
+	}
+	k.prune(now)
+
+	return k.current(), nil
+}
+
+// Lookup returns the public key for kid if it may still verify credentials.
+func (k *Keyring) Lookup(kid string) (*ecdsa.PublicKey, bool) {
+	for _, key := range k.verifying() {
+		if key.ID == kid {
+			return &key.PrivateKey.PublicKey, true
+		}
+	}
+	return nil, false
+}
+
+// KeyFunc resolves the verification key for an ES256 token by its kid
+// header.
+func (k *Keyring) KeyFunc(token *jwt.Token) (any, error) {
+	if token.Method.Alg() != jwt.SigningMethodES256.Alg() {
+		return nil, fmt.Errorf("unexpected jwt signing method=%v", token.Header["alg"])
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	publicKey, ok := k.Lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return publicKey, nil
+}
+
+// Sign signs claims with the current key, setting the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, err := k.Current()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// JWKS returns the public keys that currently verify credentials.
+func (k *Keyring) JWKS() types.JWKS {
+	jwks := types.JWKS{Keys: []types.JWK{}}
+	for _, key := range k.verifying() {
+		jwk := publicJWK(&key.PrivateKey.PublicKey)
+		jwk.KeyID = key.ID
+		jwk.Algorithm = jwt.SigningMethodES256.Alg()
+		jwk.Use = "sig"
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+func (k *Keyring) current() *Key {
+	return k.keys[len(k.keys)-1]
+}
+
+func (k *Keyring) verifying() []*Key {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.prune(k.opts.Now())
+	return append([]*Key{}, k.keys...)
+}
+
+func (k *Keyring) rotate(now time.Time) error {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptoRand.Reader)
+	if err != nil {
+		return err
+	}
+	key, err := newKey(privateKey, now)
+	if err != nil {
+		return err
+	}
+
+	if k.opts.Dir != "" {
+		if err := writeKey(k.path(key), key); err != nil {
+			return err
+		}
+	}
+
+	k.keys = append(k.keys, key)
+	return nil
+}
+
+// prune drops keys that were replaced more than VerifyFor ago.
+func (k *Keyring) prune(now time.Time) {
+	var kept []*Key
+	for i, key := range k.keys {
+		if i+1 < len(k.keys) && now.Sub(k.keys[i+1].CreatedAt) >= k.opts.VerifyFor {
+			if k.opts.Dir != "" {
+				os.Remove(k.path(key))
+			}
+			continue
+		}
+		kept = append(kept, key)
+	}
+	k.keys = kept
+}
+
+func (k *Keyring) path(key *Key) string {
+	return filepath.Join(k.opts.Dir, key.ID+".pem")
+}
+
+func newKey(privateKey *ecdsa.PrivateKey, createdAt time.Time) (*Key, error) {
+	id, err := Thumbprint(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{ID: id, PrivateKey: privateKey, CreatedAt: createdAt}, nil
+}
+
+func readKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+	createdAt, err := time.Parse(time.RFC3339, block.Headers[pemCreatedHeader])
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid %s header: %w", path, pemCreatedHeader, err)
+	}
+	privateKey, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return newKey(privateKey, createdAt)
+}
+
+func writeKey(path string, key *Key) error {
+	der, err := x509.MarshalECPrivateKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    pemType,
+		Headers: map[string]string{pemCreatedHeader: key.CreatedAt.UTC().Format(time.RFC3339)},
+		Bytes:   der,
+	})
+
+	// Write then rename so a crash never leaves a truncated key behind.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func publicJWK(publicKey *ecdsa.PublicKey) types.JWK {
+	size := (publicKey.Curve.Params().BitSize + 7) / 8
+	return types.JWK{
+		KeyType: "EC",
+		Curve:   publicKey.Curve.Params().Name,
+		X:       base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size))),
+		Y:       base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size))),
+	}
+}
+
+// Thumbprint returns the RFC 7638 JWK thumbprint of publicKey.
+func Thumbprint(publicKey *ecdsa.PublicKey) (string, error) {
+	jwk := publicJWK(publicKey)
+	if jwk.Curve != "P-256" {
+		return "", fmt.Errorf("unsupported curve %s", jwk.Curve)
+	}
+
+	// Members in lexicographic order with no whitespace, per RFC 7638.
+	canonical := fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, jwk.Curve, jwk.KeyType, jwk.X, jwk.Y)
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// PublicKey decodes an EC public key from its JWK representation.
+func PublicKey(jwk types.JWK) (*ecdsa.PublicKey, error) {
+	if jwk.KeyType != "EC" || !strings.EqualFold(jwk.Curve, "P-256") {
+		return nil, fmt.Errorf("unsupported key type %s %s", jwk.KeyType, jwk.Curve)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x coordinate: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y coordinate: %w", err)
+	}
+
+	// Validates the point is on the curve.
+	if _, err := ecdh.P256().NewPublicKey(append([]byte{4}, append(x, y...)...)); err != nil {
+		return nil, err
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
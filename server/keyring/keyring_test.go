@@ -0,0 +1,156 @@
+package keyring
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func TestKeyring_SignAndVerify(t *testing.T) {
+	k, err := New(Options{})
+	require.NoError(t, err)
+
+	signed, err := k.Sign(jwt.MapClaims{"org": "OrgA"})
+	require.NoError(t, err)
+
+	token, err := jwt.Parse(signed, k.KeyFunc)
+	require.NoError(t, err)
+	assert.True(t, token.Valid)
+
+	current, err := k.Current()
+	require.NoError(t, err)
+	assert.Equal(t, current.ID, token.Header["kid"])
+}
+
+func TestKeyring_RejectsUnknownKid(t *testing.T) {
+	signer, _ := New(Options{})
+	verifier, _ := New(Options{})
+	verifier.Current()
+
+	signed, err := signer.Sign(jwt.MapClaims{"org": "OrgA"})
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(signed, verifier.KeyFunc)
+	assert.ErrorContains(t, err, "unknown signing key")
+}
+
+func TestKeyring_RotationKeepsVerifying(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	k, err := New(Options{RotateEvery: 24 * time.Hour, VerifyFor: 48 * time.Hour, Now: clock.Now})
+	require.NoError(t, err)
+
+	first, err := k.Current()
+	require.NoError(t, err)
+
+	clock.now = clock.now.Add(25 * time.Hour)
+	second, err := k.Current()
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	// The replaced key verifies until VerifyFor has passed since rotation.
+	_, ok := k.Lookup(first.ID)
+	assert.True(t, ok)
+	assert.Len(t, k.JWKS().Keys, 2)
+
+	clock.now = clock.now.Add(48 * time.Hour)
+	_, ok = k.Lookup(first.ID)
+	assert.False(t, ok)
+	_, ok = k.Lookup(second.ID)
+	assert.True(t, ok)
+}
+
+func TestKeyring_Persists(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	opts := Options{Dir: dir, RotateEvery: 24 * time.Hour, VerifyFor: 48 * time.Hour, Now: clock.Now}
+
+	k, err := New(opts)
+	require.NoError(t, err)
+	first, err := k.Current()
+	require.NoError(t, err)
+	clock.now = clock.now.Add(25 * time.Hour)
+	second, err := k.Current()
+	require.NoError(t, err)
+
+	reopened, err := New(opts)
+	require.NoError(t, err)
+	current, err := reopened.Current()
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, current.ID)
+	_, ok := reopened.Lookup(first.ID)
+	assert.True(t, ok)
+
+	// Pruned keys are removed from disk as well.
+	clock.now = clock.now.Add(48 * time.Hour)
+	reopened.Current()
+	reopened, err = New(opts)
+	require.NoError(t, err)
+	_, ok = reopened.Lookup(first.ID)
+	assert.False(t, ok)
+}
+
+func TestKeyring_Static(t *testing.T) {
+	generated, _ := New(Options{})
+	key, err := generated.Current()
+	require.NoError(t, err)
+
+	k, err := NewStatic(key.PrivateKey)
+	require.NoError(t, err)
+	current, err := k.Current()
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, current.ID)
+}
+
+func TestKeyring_StaticFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log-key.pem")
+
+	created, err := NewStaticFile(path)
+	require.NoError(t, err)
+	key, err := created.Current()
+	require.NoError(t, err)
+
+	reopened, err := NewStaticFile(path)
+	require.NoError(t, err)
+	current, err := reopened.Current()
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, current.ID)
+
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0600))
+	_, err = NewStaticFile(path)
+	assert.Error(t, err)
+}
+
+func TestJWKS_RoundTrip(t *testing.T) {
+	k, _ := New(Options{})
+	key, err := k.Current()
+	require.NoError(t, err)
+
+	jwks := k.JWKS()
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, key.ID, jwks.Keys[0].KeyID)
+	assert.Equal(t, "ES256", jwks.Keys[0].Algorithm)
+
+	publicKey, err := PublicKey(jwks.Keys[0])
+	require.NoError(t, err)
+	assert.True(t, publicKey.Equal(&key.PrivateKey.PublicKey))
+
+	thumbprint, err := Thumbprint(publicKey)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, thumbprint)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, err := ParsePrivateKey([]byte("not pem"))
+	assert.Error(t, err)
+}
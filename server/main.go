@@ -1,21 +1,80 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/orgs"
 	"github.com/berkmancenter/rendezvous-point/router"
+	"github.com/berkmancenter/rendezvous-point/store"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		if err := runAdmin(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	port := flag.Int("port", 8080, "Port to listen on")
 	overrideIP := flag.String("remote-ip-override", "", "Override remote IP for testing")
+	remoteIPHeader := flag.String("remote-ip-header", "", "For testing, take the remote IP from this request header when present on a connection from loopback, so one local client can act as many")
+	storeBackend := flag.String("store", "memory", "Storage backend: memory or bolt")
+	dbPath := flag.String("db", "rendezvous.db", "Database file for the bolt storage backend")
+	storeKeyFile := flag.String("store-key-file", "", "File holding the base64 key shares are encrypted under at rest (defaults to $RENDEZVOUS_STORE_KEY)")
+	storeRekeyFile := flag.String("store-rekey-file", "", "Re-encrypt stored shares under the key in this file, then continue with it")
+	generateStoreKey := flag.Bool("generate-store-key", false, "Print a new random store key and exit")
+	signingKeyDir := flag.String("signing-key-dir", "", "Directory persisting credential signing keys (defaults to $RENDEZVOUS_SIGNING_KEY, else keys are lost on restart)")
+	logKeyFile := flag.String("log-key-file", "", "PEM file holding the key that signs directories and log tree heads, created if missing (defaults to $RENDEZVOUS_LOG_KEY, else the key is lost on restart)")
+	signingKeyRotation := flag.Duration("signing-key-rotation", router.DefaultKeyringOptions().RotateEvery, "How often to rotate the credential signing key")
+	orgResolver := flag.String("org-resolver", "rdap", "Comma separated organization resolvers tried in order: rdap, cidr:<csv file>, asn:<ip2asn tsv file>")
+	orgCacheTTL := flag.Duration("org-cache-ttl", orgs.DefaultCacheOptions().TTL, "How long to cache resolved organizations per network prefix (0 disables the cache)")
+	orgCacheSize := flag.Int("org-cache-size", orgs.DefaultCacheOptions().MaxEntries, "Maximum number of cached organization lookups")
+	orgAliases := flag.String("org-aliases", "", "JSON file mapping canonical organization names to their alias names, handles and ASNs")
+	threshold := flag.Int("threshold", 3, "Shares needed from one organization before any are released")
+	orgThresholds := flag.String("org-thresholds", "", "JSON file mapping canonical organization names to thresholds overriding -threshold")
+	shareRetention := flag.Duration("share-retention", 30*24*time.Hour, "How long undelivered shares are kept before they are purged (0 keeps them forever)")
+	thresholdWindow := flag.Duration("threshold-window", 0, "How recent shares must be to count toward a threshold (defaults to -share-retention)")
+	challengeTTL := flag.Duration("challenge-ttl", router.DefaultChallengeTTL, "How long a recipient has to answer an inbox challenge")
+	maxChallenges := flag.Int("max-challenges", 5, "Maximum unanswered inbox challenges per recipient")
+	maxRegisterChallenges := flag.Int("max-register-challenges", 5, "Maximum unanswered registration challenges per key")
+	sessionLifetime := flag.Duration("session-lifetime", router.DefaultSessionLifetime, "How long an inbox session lasts after a challenge is answered")
+	maxTokenKeys := flag.Int("max-token-keys", 1000, "Maximum organizations issued a blind signing key per token epoch")
+	rotationShares := flag.String("rotation-shares", string(router.ReaddressShares), "What happens to pending shares when a recipient rotates its key: readdress or discard")
+	adminTokenFile := flag.String("admin-token-file", "", "File holding the token that authenticates the /admin API (defaults to $RENDEZVOUS_ADMIN_TOKEN, else the API is off)")
 	flag.Parse()
 
+	adminToken, err := loadAdminToken(*adminTokenFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shareRotation := router.ShareRotation(*rotationShares)
+	if shareRotation != router.ReaddressShares && shareRotation != router.DiscardShares {
+		log.Fatalf("unknown -rotation-shares policy %q", *rotationShares)
+	}
+
+	if *generateStoreKey {
+		fmt.Println(store.GenerateKey())
+		return
+	}
+
 	e := echo.New()
 	e.HideBanner = true
 	e.Use(middleware.Logger())
@@ -26,9 +85,231 @@ func main() {
 		e.IPExtractor = func(*http.Request) string {
 			return *overrideIP
 		}
+	} else if *remoteIPHeader != "" {
+		log.Printf("warning: taking the remote IP from the %s header on loopback connections; local clients can claim any organization", *remoteIPHeader)
+		e.IPExtractor = loopbackHeaderIP(*remoteIPHeader)
 	}
 
-	router.RegisterRoutes(e)
+	var s store.Store
+	switch *storeBackend {
+	case "memory":
+		s = store.NewMemory()
+	case "bolt":
+		sealer, err := loadSealer(*storeKeyFile, os.Getenv("RENDEZVOUS_STORE_KEY"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		b, err := store.OpenBolt(*dbPath, sealer)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *storeRekeyFile != "" {
+			next, err := loadSealer(*storeRekeyFile, "")
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := b.Rekey(next); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("re-encrypted %s under %s", *dbPath, *storeRekeyFile)
+		}
 
-	e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", *port)))
+		s = b
+	default:
+		log.Fatalf("unknown storage backend %q", *storeBackend)
+	}
+
+	keys, err := loadKeyring(*signingKeyDir, *signingKeyRotation, os.Getenv("RENDEZVOUS_SIGNING_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	logKey, err := loadLogKey(*logKeyFile, os.Getenv("RENDEZVOUS_LOG_KEY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	resolver, err := orgs.Load(*orgResolver)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *orgCacheTTL > 0 {
+		opts := orgs.DefaultCacheOptions()
+		opts.TTL = *orgCacheTTL
+		opts.MaxEntries = *orgCacheSize
+		resolver = orgs.NewCache(resolver, opts)
+	}
+
+	var aliases *orgs.Aliases
+	if *orgAliases != "" {
+		aliases, err = orgs.LoadAliasFile(*orgAliases)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	thresholds, err := loadThresholds(*threshold, *orgThresholds)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *shareRetention > 0 {
+		go store.SweepShares(ctx, s, *shareRetention, time.Hour)
+	}
+	go store.SweepChallenges(ctx, s, *challengeTTL, *challengeTTL)
+	if *thresholdWindow == 0 {
+		*thresholdWindow = *shareRetention
+	}
+
+	router.RegisterRoutes(e, router.Config{
+		Store:                 s,
+		Keys:                  keys,
+		LogKey:                logKey,
+		Resolver:              resolver,
+		Aliases:               aliases,
+		Thresholds:            thresholds,
+		ThresholdWindow:       *thresholdWindow,
+		ChallengeTTL:          *challengeTTL,
+		MaxChallenges:         *maxChallenges,
+		MaxRegisterChallenges: *maxRegisterChallenges,
+		SessionLifetime:       *sessionLifetime,
+		ShareRotation:         shareRotation,
+		MaxTokenKeys:          *maxTokenKeys,
+		AdminToken:            adminToken,
+	})
+
+	err = serve(ctx, e, fmt.Sprintf(":%d", *port))
+	if closer, ok := s.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("close store: %v", err)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve runs e until it fails or ctx is done, then lets requests in flight
+// finish so the store can be closed cleanly.
+func serve(ctx context.Context, e *echo.Echo, address string) error {
+	errs := make(chan error, 1)
+	go func() { errs <- e.Start(address) }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// loadKeyring persists rotating signing keys in dir, or else uses a fixed PEM
+// encoded key.
+func loadKeyring(dir string, rotateEvery time.Duration, encoded string) (*keyring.Keyring, error) {
+	if dir == "" && encoded != "" {
+		privateKey, err := keyring.ParsePrivateKey([]byte(encoded))
+		if err != nil {
+			return nil, err
+		}
+		return keyring.NewStatic(privateKey)
+	}
+
+	opts := router.DefaultKeyringOptions()
+	opts.Dir = dir
+	opts.RotateEvery = rotateEvery
+	return keyring.New(opts)
+}
+
+// loadLogKey loads the fixed key directories and tree heads are signed with
+// from path, or else from a PEM encoded key. With neither, the router
+// generates one that lasts until restart.
+func loadLogKey(path string, encoded string) (*keyring.Keyring, error) {
+	switch {
+	case path != "":
+		return keyring.NewStaticFile(path)
+	case encoded != "":
+		privateKey, err := keyring.ParsePrivateKey([]byte(encoded))
+		if err != nil {
+			return nil, err
+		}
+		return keyring.NewStatic(privateKey)
+	}
+	return nil, nil
+}
+
+// loadThresholds combines the default threshold with per-organization
+// overrides read from a JSON object at path, if set.
+func loadThresholds(threshold int, path string) (router.Thresholds, error) {
+	t := router.Thresholds{Default: threshold}
+	if threshold < 1 {
+		return t, fmt.Errorf("threshold must be at least 1, got %d", threshold)
+	}
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(data, &t.Orgs); err != nil {
+		return t, fmt.Errorf("%s: %w", path, err)
+	}
+	for org, n := range t.Orgs {
+		if n < 1 {
+			return t, fmt.Errorf("%s: threshold for %q must be at least 1, got %d", path, org, n)
+		}
+	}
+	return t, nil
+}
+
+// loadSealer reads the store key from path, falling back to an encoded key,
+// and returns nil if neither is set.
+func loadSealer(path string, encoded string) (*store.Sealer, error) {
+	var (
+		key []byte
+		err error
+	)
+	switch {
+	case path != "":
+		key, err = store.ReadKeyFile(path)
+	case encoded != "":
+		key, err = store.ParseKey(encoded)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return store.NewSealer(key)
+}
+
+// loopbackHeaderIP takes the remote IP from header, but only on connections
+// from loopback, such as a local test cluster's. Anyone else could claim any
+// organization with it, so their address is always the connection's.
+func loopbackHeaderIP(header string) echo.IPExtractor {
+	direct := echo.ExtractIPDirect()
+	return func(req *http.Request) string {
+		ip := direct(req)
+		if addr, err := netip.ParseAddr(ip); err != nil || !addr.IsLoopback() {
+			return ip
+		}
+		if forwarded := req.Header.Get(header); forwarded != "" {
+			return forwarded
+		}
+		return ip
+	}
 }
@@ -0,0 +1,138 @@
+package orgs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// legalSuffixes are dropped when comparing names, so "Example, Inc." and
+// "EXAMPLE INC" group together.
+var legalSuffixes = map[string]bool{
+	"ag": true, "bv": true, "co": true, "company": true, "corp": true,
+	"corporation": true, "gmbh": true, "inc": true, "incorporated": true,
+	"limited": true, "llc": true, "llp": true, "lp": true, "ltd": true,
+	"plc": true, "sa": true, "sarl": true,
+}
+
+// Normalize returns the key organization names are compared by: case
+// folded, punctuation and legal suffixes removed, whitespace collapsed.
+func Normalize(name string) string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '&'
+	})
+	for len(fields) > 1 && legalSuffixes[fields[len(fields)-1]] {
+		fields = fields[:len(fields)-1]
+	}
+	return strings.Join(fields, " ")
+}
+
+// Aliases maps the names, registry handles and ASNs an employer appears
+// under onto one canonical organization name.
+//
+// A nil *Aliases only tidies whitespace.
+type Aliases struct {
+	names   map[string]string // Normalize(name) -> canonical
+	handles map[string]string // upper case handle -> canonical
+	asns    map[uint32]string
+}
+
+// LoadAliasFile reads a JSON object mapping each canonical name to its
+// aliases. An alias is a name, "handle:<registry handle>" or "as:<number>":
+//
+//	{"Harvard University": ["President and Fellows of Harvard College", "handle:HARVAR", "as:11"]}
+func LoadAliasFile(path string) (*Aliases, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	a, err := ParseAliases(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return a, nil
+}
+
+func ParseAliases(r io.Reader) (*Aliases, error) {
+	var table map[string][]string
+	if err := json.NewDecoder(r).Decode(&table); err != nil {
+		return nil, err
+	}
+
+	a := &Aliases{
+		names:   map[string]string{},
+		handles: map[string]string{},
+		asns:    map[uint32]string{},
+	}
+	for canonical, aliases := range table {
+		canonical = tidy(canonical)
+		if err := a.add(canonical, canonical); err != nil {
+			return nil, err
+		}
+		for _, alias := range aliases {
+			if err := a.add(alias, canonical); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a, nil
+}
+
+func (a *Aliases) add(alias string, canonical string) error {
+	kind, value, found := strings.Cut(alias, ":")
+	switch {
+	case found && kind == "handle":
+		return claim(a.handles, strings.ToUpper(strings.TrimSpace(value)), canonical, alias)
+	case found && kind == "as":
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "AS"), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid ASN alias %q", alias)
+		}
+		return claim(a.asns, uint32(asn), canonical, alias)
+	default:
+		return claim(a.names, Normalize(alias), canonical, alias)
+	}
+}
+
+func claim[K comparable](m map[K]string, key K, canonical string, alias string) error {
+	if existing, ok := m[key]; ok && existing != canonical {
+		return fmt.Errorf("alias %q maps to both %q and %q", alias, existing, canonical)
+	}
+	m[key] = canonical
+	return nil
+}
+
+// Canonical returns the canonical name for a resolved organization,
+// matching its handle, then ASN, then name.
+func (a *Aliases) Canonical(org *Organization) string {
+	if a != nil {
+		if canonical, ok := a.handles[strings.ToUpper(org.Handle)]; ok && org.Handle != "" {
+			return canonical
+		}
+		if canonical, ok := a.asns[org.ASN]; ok && org.ASN != 0 {
+			return canonical
+		}
+	}
+	return a.CanonicalName(org.Name)
+}
+
+// CanonicalName returns the canonical name for an organization name, such
+// as one carried by an already issued credential.
+func (a *Aliases) CanonicalName(name string) string {
+	if a != nil {
+		if canonical, ok := a.names[Normalize(name)]; ok {
+			return canonical
+		}
+	}
+	return tidy(name)
+}
+
+func tidy(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
@@ -0,0 +1,60 @@
+package orgs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAliases = `{
+  "Harvard University": [
+    "President and Fellows of Harvard College",
+    "handle:HARVAR-Z",
+    "as:AS11"
+  ]
+}`
+
+func TestNormalize(t *testing.T) {
+	for _, name := range []string{"Example, Inc.", "EXAMPLE INC", "  example  ", "Example Corp LLC"} {
+		assert.Equal(t, "example", Normalize(name), name)
+	}
+	assert.Equal(t, "at&t", Normalize("AT&T Corp."))
+	assert.Equal(t, "llc", Normalize("LLC"))
+	assert.NotEqual(t, Normalize("Harvard University"), Normalize("Harvard College"))
+}
+
+func TestAliases_Canonical(t *testing.T) {
+	a, err := ParseAliases(strings.NewReader(testAliases))
+	require.NoError(t, err)
+
+	for _, org := range []*Organization{
+		{Name: "Harvard University"},
+		{Name: "PRESIDENT AND FELLOWS OF HARVARD COLLEGE"},
+		{Name: "HU-NET", Handle: "harvar-z"},
+		{Name: "AS11", ASN: 11},
+	} {
+		assert.Equal(t, "Harvard University", a.Canonical(org), org.Name)
+	}
+
+	assert.Equal(t, "Example Corp", a.Canonical(&Organization{Name: " Example  Corp "}))
+	assert.Equal(t, "Harvard University", a.CanonicalName("President and Fellows of Harvard College, Inc."))
+}
+
+func TestAliases_Nil(t *testing.T) {
+	var a *Aliases
+	assert.Equal(t, "Example Corp", a.Canonical(&Organization{Name: "Example   Corp", Handle: "EX", ASN: 1}))
+	assert.Equal(t, "Example Corp", a.CanonicalName("Example Corp"))
+}
+
+func TestParseAliases_Errors(t *testing.T) {
+	_, err := ParseAliases(strings.NewReader(`{"A": ["as:nope"]}`))
+	assert.ErrorContains(t, err, "invalid ASN alias")
+
+	_, err = ParseAliases(strings.NewReader(`{"A": ["Shared Name"], "B": ["shared name"]}`))
+	assert.ErrorContains(t, err, "maps to both")
+
+	_, err = ParseAliases(strings.NewReader(`["not", "an", "object"]`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package orgs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type asnRange struct {
+	start, end netip.Addr
+	asn        uint32
+	name       string
+}
+
+// ASN resolves organizations offline from an IP to ASN database, such as
+// the ip2asn dumps published by iptoasn.com.
+type ASN struct {
+	ranges []asnRange // sorted by start, non-overlapping
+}
+
+// LoadASNFile reads a tab separated database with one
+// "range_start range_end AS_number country_code AS_description" row per
+// range. Ranges announced by AS 0 are treated as unrouted.
+func LoadASNFile(path string) (*ASN, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	a, err := ParseASN(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return a, nil
+}
+
+func ParseASN(r io.Reader) (*ASN, error) {
+	a := &ASN{}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("line %d: expected 5 fields, got %d", line, len(fields))
+		}
+		start, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := netip.ParseAddr(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, start, end)
+		}
+		asn, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if asn == 0 {
+			continue
+		}
+
+		a.ranges = append(a.ranges, asnRange{start: start, end: end, asn: uint32(asn), name: fields[4]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(a.ranges, func(i, j int) bool {
+		return a.ranges[i].start.Less(a.ranges[j].start)
+	})
+	return a, nil
+}
+
+func (a *ASN) Resolve(addr netip.Addr) (*Organization, error) {
+	// Find the last range starting at or before addr.
+	i := sort.Search(len(a.ranges), func(i int) bool {
+		return addr.Less(a.ranges[i].start)
+	}) - 1
+	if i < 0 || a.ranges[i].end.Less(addr) || a.ranges[i].start.Is4() != addr.Is4() {
+		return nil, ErrNotFound
+	}
+
+	r := a.ranges[i]
+	return &Organization{
+		Name:   r.name,
+		ASN:    r.asn,
+		Prefix: coveringPrefix(addr, r.start, r.end),
+	}, nil
+}
@@ -0,0 +1,44 @@
+package orgs
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testASN = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.1.0\t1.0.3.255\t0\tNone\tNot routed\n" +
+	"8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE\n" +
+	"2001:4860::\t2001:4860:ffff:ffff:ffff:ffff:ffff:ffff\t15169\tUS\tGOOGLE\n"
+
+func TestASN_Resolve(t *testing.T) {
+	a, err := ParseASN(strings.NewReader(testASN))
+	require.NoError(t, err)
+
+	org, err := a.Resolve(netip.MustParseAddr("8.8.8.8"))
+	assert.NoError(t, err)
+	assert.Equal(t, "GOOGLE", org.Name)
+	assert.Equal(t, uint32(15169), org.ASN)
+	assert.Equal(t, netip.MustParsePrefix("8.8.8.0/24"), org.Prefix)
+
+	org, err = a.Resolve(netip.MustParseAddr("2001:4860:4860::8888"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(15169), org.ASN)
+	assert.Equal(t, netip.MustParsePrefix("2001:4860::/32"), org.Prefix)
+
+	for _, addr := range []string{"1.0.2.1", "0.0.0.1", "9.9.9.9", "::1"} {
+		_, err = a.Resolve(netip.MustParseAddr(addr))
+		assert.ErrorIs(t, err, ErrNotFound, addr)
+	}
+}
+
+func TestASN_ParseErrors(t *testing.T) {
+	_, err := ParseASN(strings.NewReader("8.8.8.0\t8.8.8.255\t15169\n"))
+	assert.ErrorContains(t, err, "line 1")
+
+	_, err = ParseASN(strings.NewReader("8.8.8.255\t8.8.8.0\t15169\tUS\tGOOGLE\n"))
+	assert.ErrorContains(t, err, "invalid range")
+}
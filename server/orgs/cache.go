@@ -0,0 +1,182 @@
+package orgs
+
+import (
+	"container/list"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	// Failures are remembered per network of this size rather than per
+	// address, so the cache never holds an individual requester's IP.
+	negativeBits4 = 24
+	negativeBits6 = 48
+)
+
+type CacheOptions struct {
+	// TTL is how long a resolved organization is served without asking the
+	// underlying resolver again.
+	TTL time.Duration
+	// StaleFor is how long past its TTL an entry is still served while it
+	// is refreshed in the background.
+	StaleFor time.Duration
+	// NegativeTTL is how long a failed lookup is remembered.
+	NegativeTTL time.Duration
+	// MaxEntries bounds the cache, evicting the least recently used entry.
+	MaxEntries int
+	// Now overrides the clock, for tests.
+	Now func() time.Time
+}
+
+func DefaultCacheOptions() CacheOptions {
+	return CacheOptions{
+		TTL:         24 * time.Hour,
+		StaleFor:    24 * time.Hour,
+		NegativeTTL: 5 * time.Minute,
+		MaxEntries:  10000,
+	}
+}
+
+type cacheEntry struct {
+	prefix     netip.Prefix
+	org        *Organization
+	err        error
+	expires    time.Time
+	refreshing bool
+	element    *list.Element
+}
+
+// Cache wraps a Resolver, remembering answers for the network prefix they
+// cover. Results for networks narrower than a /24 (IPv4) or /48 (IPv6) are
+// not cached, so that no entry identifies a single requester.
+type Cache struct {
+	resolver Resolver
+	opts     CacheOptions
+
+	mu      sync.Mutex
+	entries map[netip.Prefix]*cacheEntry
+	bits    map[int]int // prefix length -> number of entries
+	lru     *list.List  // most recently used first
+}
+
+func NewCache(resolver Resolver, opts CacheOptions) *Cache {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Cache{
+		resolver: resolver,
+		opts:     opts,
+		entries:  map[netip.Prefix]*cacheEntry{},
+		bits:     map[int]int{},
+		lru:      list.New(),
+	}
+}
+
+func (c *Cache) Resolve(addr netip.Addr) (*Organization, error) {
+	c.mu.Lock()
+	now := c.opts.Now()
+	if entry := c.lookup(addr); entry != nil {
+		switch {
+		case now.Before(entry.expires):
+			c.lru.MoveToFront(entry.element)
+			c.mu.Unlock()
+			return entry.org, entry.err
+		case entry.err == nil && now.Before(entry.expires.Add(c.opts.StaleFor)):
+			c.lru.MoveToFront(entry.element)
+			if !entry.refreshing {
+				entry.refreshing = true
+				go c.refresh(addr, entry)
+			}
+			c.mu.Unlock()
+			return entry.org, nil
+		default:
+			c.remove(entry)
+		}
+	}
+	c.mu.Unlock()
+
+	org, err := c.resolver.Resolve(addr)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(addr, org, err)
+	return org, err
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+func (c *Cache) refresh(addr netip.Addr, stale *cacheEntry) {
+	org, err := c.resolver.Resolve(addr)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stale.refreshing = false
+	if err != nil {
+		// Keep serving the stale answer until it ages out.
+		return
+	}
+	c.store(addr, org, nil)
+}
+
+// lookup returns the most specific entry covering addr.
+func (c *Cache) lookup(addr netip.Addr) *cacheEntry {
+	for bits := addr.BitLen(); bits >= 0; bits-- {
+		if c.bits[bits] == 0 {
+			continue
+		}
+		prefix, _ := addr.Prefix(bits)
+		if entry, ok := c.entries[prefix]; ok {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (c *Cache) store(addr netip.Addr, org *Organization, err error) {
+	entry := &cacheEntry{org: org, err: err}
+	if err != nil {
+		entry.prefix, _ = addr.Prefix(negativeBits(addr))
+		entry.expires = c.opts.Now().Add(c.opts.NegativeTTL)
+	} else {
+		if !org.Prefix.IsValid() || org.Prefix.Bits() > negativeBits(addr) || !org.Prefix.Contains(addr) {
+			return
+		}
+		entry.prefix = org.Prefix.Masked()
+		entry.expires = c.opts.Now().Add(c.opts.TTL)
+	}
+
+	if existing, ok := c.entries[entry.prefix]; ok {
+		c.remove(existing)
+	}
+	entry.element = c.lru.PushFront(entry)
+	c.entries[entry.prefix] = entry
+	c.bits[entry.prefix.Bits()]++
+
+	for c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries {
+		c.remove(c.lru.Back().Value.(*cacheEntry))
+	}
+}
+
+func (c *Cache) remove(entry *cacheEntry) {
+	if c.entries[entry.prefix] != entry {
+		return
+	}
+	c.lru.Remove(entry.element)
+	delete(c.entries, entry.prefix)
+	c.bits[entry.prefix.Bits()]--
+}
+
+func negativeBits(addr netip.Addr) int {
+	if addr.Is4() {
+		return negativeBits4
+	}
+	return negativeBits6
+}
@@ -0,0 +1,211 @@
+package orgs
+
+import (
+	"errors"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeResolver answers from a fixed table and records every query.
+type fakeResolver struct {
+	mu      sync.Mutex
+	orgs    map[netip.Prefix]string
+	fail    bool
+	queries []netip.Addr
+	queried chan netip.Addr
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		orgs: map[netip.Prefix]string{
+			netip.MustParsePrefix("10.0.0.0/16"):     "Example Corp",
+			netip.MustParsePrefix("192.0.2.7/32"):    "Narrow Corp",
+			netip.MustParsePrefix("2001:db8::/32"):   "Example Corp",
+			netip.MustParsePrefix("198.51.100.0/24"): "Other Corp",
+		},
+		queried: make(chan netip.Addr, 100),
+	}
+}
+
+func (f *fakeResolver) Resolve(addr netip.Addr) (*Organization, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queries = append(f.queries, addr)
+	defer func() { f.queried <- addr }()
+
+	if f.fail {
+		return nil, errors.New("registry unavailable")
+	}
+	for prefix, name := range f.orgs {
+		if prefix.Contains(addr) {
+			return &Organization{Name: name, Prefix: prefix}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (f *fakeResolver) setFail(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fail = fail
+}
+
+func (f *fakeResolver) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queries)
+}
+
+func newTestCache(resolver Resolver) (*Cache, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return NewCache(resolver, CacheOptions{
+		TTL:         time.Hour,
+		StaleFor:    time.Hour,
+		NegativeTTL: time.Minute,
+		MaxEntries:  10,
+		Now:         clock.Now,
+	}), clock
+}
+
+func TestCache_HitsByPrefix(t *testing.T) {
+	resolver := newFakeResolver()
+	cache, _ := newTestCache(resolver)
+
+	org, err := cache.Resolve(netip.MustParseAddr("10.0.1.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp", org.Name)
+
+	// Another address in the same network is served from the cache.
+	org, err = cache.Resolve(netip.MustParseAddr("10.0.200.3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp", org.Name)
+	assert.Equal(t, 1, resolver.count())
+
+	_, err = cache.Resolve(netip.MustParseAddr("2001:db8::1"))
+	assert.NoError(t, err)
+	_, err = cache.Resolve(netip.MustParseAddr("2001:db8:1::1"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, resolver.count())
+}
+
+func TestCache_NeverStoresIndividualAddresses(t *testing.T) {
+	resolver := newFakeResolver()
+	cache, _ := newTestCache(resolver)
+
+	for i := 0; i < 2; i++ {
+		org, err := cache.Resolve(netip.MustParseAddr("192.0.2.7"))
+		assert.NoError(t, err)
+		assert.Equal(t, "Narrow Corp", org.Name)
+	}
+	assert.Equal(t, 2, resolver.count())
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestCache_Expiry(t *testing.T) {
+	resolver := newFakeResolver()
+	cache, clock := newTestCache(resolver)
+	addr := netip.MustParseAddr("10.0.1.1")
+
+	cache.Resolve(addr)
+	clock.Advance(2*time.Hour + time.Second)
+
+	// Past TTL and the stale window the entry is resolved synchronously.
+	org, err := cache.Resolve(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp", org.Name)
+	assert.Equal(t, 2, resolver.count())
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	resolver := newFakeResolver()
+	cache, clock := newTestCache(resolver)
+	addr := netip.MustParseAddr("10.0.1.1")
+
+	cache.Resolve(addr)
+	<-resolver.queried
+
+	clock.Advance(90 * time.Minute)
+	resolver.setFail(true)
+
+	// The stale answer is served immediately while a refresh runs, and
+	// survives the refresh failing.
+	org, err := cache.Resolve(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp", org.Name)
+	<-resolver.queried
+
+	resolver.setFail(false)
+	org, err = cache.Resolve(addr)
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp", org.Name)
+	<-resolver.queried
+
+	// Once refreshed the entry is fresh again.
+	assert.Eventually(t, func() bool {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		entry := cache.lookup(addr)
+		return entry != nil && entry.expires.After(clock.Now())
+	}, time.Second, time.Millisecond)
+	clock.Advance(30 * time.Minute)
+	cache.Resolve(addr)
+	assert.Equal(t, 3, resolver.count())
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	resolver := newFakeResolver()
+	cache, clock := newTestCache(resolver)
+
+	_, err := cache.Resolve(netip.MustParseAddr("203.0.113.5"))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Failures are remembered for the surrounding /24.
+	_, err = cache.Resolve(netip.MustParseAddr("203.0.113.99"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 1, resolver.count())
+
+	clock.Advance(2 * time.Minute)
+	_, err = cache.Resolve(netip.MustParseAddr("203.0.113.5"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, resolver.count())
+}
+
+func TestCache_SizeBound(t *testing.T) {
+	resolver := newFakeResolver()
+	cache, _ := newTestCache(resolver)
+
+	for i := 0; i < 20; i++ {
+		cache.Resolve(netip.AddrFrom4([4]byte{203, 0, byte(i), 1}))
+	}
+	assert.Equal(t, 10, cache.Len())
+
+	// The most recently used entries survive eviction.
+	_, err := cache.Resolve(netip.MustParseAddr("203.0.19.1"))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 20, resolver.count())
+
+	cache.Resolve(netip.MustParseAddr("203.0.0.1"))
+	assert.Equal(t, 21, resolver.count())
+}
@@ -0,0 +1,100 @@
+package orgs
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// CIDR resolves organizations from a fixed prefix to organization mapping,
+// preferring the most specific matching prefix.
+type CIDR struct {
+	byBits  map[int]map[netip.Prefix]string
+	bits    []int        // prefix lengths present, longest first
+	longest map[bool]int // is IPv4 -> longest prefix length present
+}
+
+// LoadCIDRFile reads a CSV file of "prefix,organization" rows. Blank lines
+// and lines starting with # are ignored.
+func LoadCIDRFile(path string) (*CIDR, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := ParseCIDR(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func NewCIDR() *CIDR {
+	return &CIDR{byBits: map[int]map[netip.Prefix]string{}, longest: map[bool]int{}}
+}
+
+func ParseCIDR(r io.Reader) (*CIDR, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	c := NewCIDR()
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, err
+		}
+		c.Add(prefix, strings.TrimSpace(record[1]))
+	}
+	return c, nil
+}
+
+// Add maps every address in prefix to name.
+func (c *CIDR) Add(prefix netip.Prefix, name string) {
+	prefix = prefix.Masked()
+	if c.byBits[prefix.Bits()] == nil {
+		c.byBits[prefix.Bits()] = map[netip.Prefix]string{}
+
+		i := 0
+		for i < len(c.bits) && c.bits[i] > prefix.Bits() {
+			i++
+		}
+		c.bits = append(c.bits[:i], append([]int{prefix.Bits()}, c.bits[i:]...)...)
+	}
+	c.byBits[prefix.Bits()][prefix] = name
+
+	is4 := prefix.Addr().Is4()
+	c.longest[is4] = max(c.longest[is4], prefix.Bits())
+}
+
+func (c *CIDR) Resolve(addr netip.Addr) (*Organization, error) {
+	for _, bits := range c.bits {
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if name, ok := c.byBits[bits][prefix]; ok {
+			// A more specific entry may carve out part of the matching
+			// prefix, so only claim the network down to the longest
+			// prefix length in use.
+			if longest := c.longest[addr.Is4()]; longest > bits {
+				prefix, _ = addr.Prefix(longest)
+			}
+			return &Organization{Name: name, Prefix: prefix}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,49 @@
+package orgs
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCIDR = `# prefix,organization
+10.0.0.0/8, Example Corp
+10.1.0.0/16, "Example Corp, Research Division"
+2001:db8::/32, Example Corp
+`
+
+func TestCIDR_Resolve(t *testing.T) {
+	c, err := ParseCIDR(strings.NewReader(testCIDR))
+	require.NoError(t, err)
+
+	org, err := c.Resolve(netip.MustParseAddr("10.1.2.3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp, Research Division", org.Name)
+	assert.Equal(t, netip.MustParsePrefix("10.1.0.0/16"), org.Prefix)
+
+	// The /8 is carved up by the /16, so only the /16 around the address
+	// is claimed.
+	org, err = c.Resolve(netip.MustParseAddr("10.2.3.4"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp", org.Name)
+	assert.Equal(t, netip.MustParsePrefix("10.2.0.0/16"), org.Prefix)
+
+	org, err = c.Resolve(netip.MustParseAddr("2001:db8::1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp", org.Name)
+	assert.Equal(t, netip.MustParsePrefix("2001:db8::/32"), org.Prefix)
+
+	_, err = c.Resolve(netip.MustParseAddr("192.0.2.1"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestCIDR_ParseErrors(t *testing.T) {
+	_, err := ParseCIDR(strings.NewReader("not-a-prefix,Example Corp\n"))
+	assert.Error(t, err)
+
+	_, err = ParseCIDR(strings.NewReader("10.0.0.0/8\n"))
+	assert.Error(t, err)
+}
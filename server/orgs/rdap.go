@@ -0,0 +1,59 @@
+package orgs
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+
+	"github.com/openrdap/rdap"
+)
+
+// RDAP resolves organizations with live queries to the regional internet
+// registries.
+type RDAP struct {
+	Client *rdap.Client
+	// Server, if set, is queried directly instead of bootstrapping the
+	// registry responsible for each address.
+	Server *url.URL
+}
+
+func NewRDAP() *RDAP {
+	return &RDAP{Client: &rdap.Client{}}
+}
+
+func (r *RDAP) Resolve(addr netip.Addr) (*Organization, error) {
+	req := rdap.NewIPRequest(net.IP(addr.AsSlice()))
+	if r.Server != nil {
+		req = req.WithServer(r.Server)
+	}
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := resp.Object.(*rdap.IPNetwork)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	org := &Organization{
+		Name:   result.Name,
+		Handle: result.Handle,
+		Prefix: netip.PrefixFrom(addr, addr.BitLen()),
+	}
+	if len(result.Entities) > 0 {
+		entity := result.Entities[0]
+		org.Handle = entity.Handle
+		if entity.VCard != nil {
+			org.Name = entity.VCard.Name()
+		}
+	}
+
+	start, startErr := netip.ParseAddr(result.StartAddress)
+	end, endErr := netip.ParseAddr(result.EndAddress)
+	if startErr == nil && endErr == nil && start.Is4() == addr.Is4() && end.Is4() == addr.Is4() {
+		org.Prefix = coveringPrefix(addr, start, end)
+	}
+
+	return org, nil
+}
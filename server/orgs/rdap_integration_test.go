@@ -0,0 +1,25 @@
+//go:build integration
+
+package orgs
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// These tests query the live registries: go test -tags integration ./orgs
+
+func TestRDAP_Live(t *testing.T) {
+	org, err := NewRDAP().Resolve(netip.MustParseAddr("8.8.8.8"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Google LLC", org.Name)
+	assert.True(t, org.Prefix.Contains(netip.MustParseAddr("8.8.8.8")))
+}
+
+func TestRDAP_LiveFailure(t *testing.T) {
+	org, err := NewRDAP().Resolve(netip.Addr{})
+	assert.Error(t, err)
+	assert.Nil(t, org)
+}
@@ -0,0 +1,76 @@
+package orgs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIPNetwork = `{
+  "objectClassName": "ip network",
+  "handle": "NET-8-8-8-0-2",
+  "startAddress": "8.8.8.0",
+  "endAddress": "8.8.8.255",
+  "ipVersion": "v4",
+  "name": "GOGL",
+  "entities": [{
+    "objectClassName": "entity",
+    "handle": "GOGL",
+    "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Google LLC"]]]
+  }]
+}`
+
+func newTestRDAP(t *testing.T, status int, body string) *RDAP {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rdap+json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	r := NewRDAP()
+	r.Server = serverURL
+	return r
+}
+
+func TestRDAP_Resolve(t *testing.T) {
+	r := newTestRDAP(t, http.StatusOK, testIPNetwork)
+
+	org, err := r.Resolve(netip.MustParseAddr("8.8.8.8"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Google LLC", org.Name)
+	assert.Equal(t, "GOGL", org.Handle)
+	assert.Equal(t, netip.MustParsePrefix("8.8.8.0/24"), org.Prefix)
+}
+
+func TestRDAP_ResolveWithoutEntities(t *testing.T) {
+	r := newTestRDAP(t, http.StatusOK, `{
+  "objectClassName": "ip network",
+  "handle": "NET-1",
+  "startAddress": "10.0.0.0",
+  "endAddress": "10.0.2.255",
+  "name": "EXAMPLE-NET"
+}`)
+
+	org, err := r.Resolve(netip.MustParseAddr("10.0.1.7"))
+	assert.NoError(t, err)
+	assert.Equal(t, "EXAMPLE-NET", org.Name)
+	assert.Equal(t, "NET-1", org.Handle)
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/23"), org.Prefix)
+}
+
+func TestRDAP_ResolveFailure(t *testing.T) {
+	r := newTestRDAP(t, http.StatusNotFound, `{}`)
+
+	org, err := r.Resolve(netip.MustParseAddr("8.8.8.8"))
+	assert.Error(t, err)
+	assert.Nil(t, org)
+}
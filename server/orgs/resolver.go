@@ -0,0 +1,112 @@
+package orgs
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+var (
+	ErrNotFound = errors.New("no organization found")
+)
+
+// Organization is the owner of the network an IP address belongs to.
+type Organization struct {
+	Name string
+	// Handle is the registry handle of the organization, if known.
+	Handle string
+	// ASN is the autonomous system announcing the network, if known.
+	ASN uint32
+	// Prefix is the network the result applies to. Every address in it
+	// resolves to the same organization.
+	Prefix netip.Prefix
+}
+
+// Resolver maps an IP address to the organization that operates it.
+type Resolver interface {
+	Resolve(addr netip.Addr) (*Organization, error)
+}
+
+// ResolverFunc adapts a function to a Resolver.
+type ResolverFunc func(addr netip.Addr) (*Organization, error)
+
+func (f ResolverFunc) Resolve(addr netip.Addr) (*Organization, error) {
+	return f(addr)
+}
+
+// Chain tries each resolver in order, returning the first answer.
+type Chain []Resolver
+
+func (c Chain) Resolve(addr netip.Addr) (*Organization, error) {
+	var errs []error
+	for _, resolver := range c {
+		org, err := resolver.Resolve(addr)
+		if err == nil {
+			return org, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, ErrNotFound
+	}
+	return nil, errors.Join(errs...)
+}
+
+// Load builds a resolver from a comma separated list of sources, tried in
+// order:
+//
+//	rdap          live RDAP queries
+//	cidr:<path>   a CSV file of "prefix,organization" rows
+//	asn:<path>    an iptoasn.com style TSV database
+func Load(spec string) (Resolver, error) {
+	var chain Chain
+	for _, source := range strings.Split(spec, ",") {
+		kind, path, _ := strings.Cut(strings.TrimSpace(source), ":")
+		switch kind {
+		case "rdap":
+			chain = append(chain, NewRDAP())
+		case "cidr":
+			resolver, err := LoadCIDRFile(path)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, resolver)
+		case "asn":
+			resolver, err := LoadASNFile(path)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, resolver)
+		default:
+			return nil, fmt.Errorf("unknown organization resolver %q", source)
+		}
+	}
+
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+	return chain, nil
+}
+
+// coveringPrefix returns the largest prefix containing addr that lies
+// entirely within [start, end].
+func coveringPrefix(addr, start, end netip.Addr) netip.Prefix {
+	for bits := 0; bits <= addr.BitLen(); bits++ {
+		prefix := netip.PrefixFrom(addr, bits).Masked()
+		if start.Compare(prefix.Addr()) <= 0 && lastAddr(prefix).Compare(end) <= 0 {
+			return prefix
+		}
+	}
+	return netip.PrefixFrom(addr, addr.BitLen())
+}
+
+// lastAddr returns the highest address in prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Masked().Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(bytes)*8; bit++ {
+		bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
@@ -0,0 +1,70 @@
+package orgs
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain_FallsBack(t *testing.T) {
+	failing := ResolverFunc(func(netip.Addr) (*Organization, error) {
+		return nil, errors.New("registry unavailable")
+	})
+	static := ResolverFunc(func(addr netip.Addr) (*Organization, error) {
+		return &Organization{Name: "Example Corp", Prefix: netip.PrefixFrom(addr, 32)}, nil
+	})
+
+	org, err := Chain{failing, static}.Resolve(netip.MustParseAddr("192.0.2.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp", org.Name)
+
+	_, err = Chain{failing, failing}.Resolve(netip.MustParseAddr("192.0.2.1"))
+	assert.ErrorContains(t, err, "registry unavailable")
+
+	_, err = Chain{}.Resolve(netip.MustParseAddr("192.0.2.1"))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	cidrPath := filepath.Join(dir, "orgs.csv")
+	asnPath := filepath.Join(dir, "ip2asn.tsv")
+	require.NoError(t, os.WriteFile(cidrPath, []byte(testCIDR), 0600))
+	require.NoError(t, os.WriteFile(asnPath, []byte(testASN), 0600))
+
+	resolver, err := Load("cidr:" + cidrPath + ", asn:" + asnPath)
+	require.NoError(t, err)
+
+	org, err := resolver.Resolve(netip.MustParseAddr("10.1.2.3"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Example Corp, Research Division", org.Name)
+
+	org, err = resolver.Resolve(netip.MustParseAddr("8.8.8.8"))
+	assert.NoError(t, err)
+	assert.Equal(t, "GOOGLE", org.Name)
+
+	resolver, err = Load("rdap")
+	assert.NoError(t, err)
+	assert.IsType(t, &RDAP{}, resolver)
+
+	_, err = Load("whois")
+	assert.ErrorContains(t, err, "unknown organization resolver")
+
+	_, err = Load("cidr:" + filepath.Join(dir, "missing.csv"))
+	assert.Error(t, err)
+}
+
+func TestCoveringPrefix(t *testing.T) {
+	addr := netip.MustParseAddr("10.0.1.7")
+	assert.Equal(t, netip.MustParsePrefix("10.0.0.0/23"),
+		coveringPrefix(addr, netip.MustParseAddr("10.0.0.0"), netip.MustParseAddr("10.0.2.255")))
+	assert.Equal(t, netip.MustParsePrefix("10.0.1.7/32"),
+		coveringPrefix(addr, addr, addr))
+	assert.Equal(t, netip.MustParsePrefix("0.0.0.0/0"),
+		coveringPrefix(addr, netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("255.255.255.255")))
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+)
+
+// adminAuth requires the operator's bearer token.
+func (s *server) adminAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			return errInvalidAuthorization.withMessage("invalid admin token")
+		}
+		return next(c)
+	}
+}
+
+// getAdminRecipients lists every recipient with its review, optionally
+// only those with the status given in the query.
+func (s *server) getAdminRecipients(c echo.Context) error {
+	status := types.RecipientStatus(c.QueryParam("status"))
+	if status != "" && !status.Valid() {
+		return errInvalidParameter.withMessage("invalid status")
+	}
+
+	recipients, err := s.store.Recipients()
+	if err != nil {
+		return errInternal.withMessage("failed to load recipients")
+	}
+
+	result := []types.Recipient{}
+	for _, r := range recipients {
+		if r.Status == "" {
+			r.Status = types.RecipientPending
+		}
+		if status == "" || r.Status == status {
+			result = append(result, r)
+		}
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// putAdminRecipient approves, rejects or suspends a recipient, or updates
+// what the operator records about it.
+func (s *server) putAdminRecipient(c echo.Context) error {
+	var review types.RecipientReview
+	if err := json.NewDecoder(c.Request().Body).Decode(&review); err != nil {
+		return errInvalidBody
+	}
+	if review.Status != "" && !review.Status.Valid() {
+		return errInvalidParameter.withMessage("invalid status")
+	}
+
+	publicKey, err := base64.RawURLEncoding.DecodeString(c.Param("key"))
+	if err != nil {
+		return errInvalidKey
+	}
+
+	r, err := s.store.Recipient(base64.StdEncoding.EncodeToString(publicKey))
+	if errors.Is(err, store.ErrNotFound) {
+		return errNotRegistered
+	} else if err != nil {
+		return errInternal.withMessage("failed to load recipient")
+	}
+	before := *r
+
+	if review.Status != "" {
+		r.Status = review.Status
+	}
+	if review.Organization != nil {
+		r.Organization = *review.Organization
+	}
+	if review.Contact != nil {
+		r.Contact = *review.Contact
+	}
+	if review.Notes != nil {
+		r.Notes = *review.Notes
+	}
+	if r.Status == "" {
+		r.Status = types.RecipientPending
+	}
+
+	if err := s.store.PutRecipient(*r); err != nil {
+		return errInternal.withMessage("failed to store recipient")
+	}
+	if err := s.logListing(types.LogReview, &before, r, ""); err != nil {
+		return errInternal.withMessage("failed to log review")
+	}
+	return c.JSON(http.StatusOK, r)
+}
@@ -0,0 +1,128 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-token"
+
+func setupAdminRouter() (*echo.Echo, store.Store) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Resolver: testResolver, AdminToken: testAdminToken})
+	return e, s
+}
+
+func adminRequest(e *echo.Echo, method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func listRecipients(t *testing.T, e *echo.Echo, path string) []types.Recipient {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var result []types.Recipient
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	return result
+}
+
+func TestAdminApproval(t *testing.T) {
+	e, _ := setupAdminRouter()
+	recipient := newTestRecipient()
+	encodedKey := base64.StdEncoding.EncodeToString(recipient.publicKey)
+	require.Equal(t, http.StatusOK, recipient.postRegister(t, e, `{"name":"Legal Team"}`).Code)
+
+	pending := listRecipients(t, e, "/admin/recipients?status=pending")
+	assert.Equal(t, []types.Recipient{{Name: "Legal Team", PublicKey: encodedKey, Status: types.RecipientPending}}, pending)
+
+	rec := adminRequest(e, http.MethodPut, "/admin/recipients/"+recipient.urlKey(),
+		`{"status":"approved","organization":"Example News","contact":"legal@example.org","notes":"verified by phone"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	approved := types.Recipient{
+		Name:         "Legal Team",
+		PublicKey:    encodedKey,
+		Status:       types.RecipientApproved,
+		Organization: "Example News",
+		Contact:      "legal@example.org",
+		Notes:        "verified by phone",
+	}
+	assert.Equal(t, []types.Recipient{approved}, listRecipients(t, e, "/admin/recipients"))
+	assert.Empty(t, listRecipients(t, e, "/admin/recipients?status=pending"))
+
+	// The directory lists approved recipients without the operator's notes.
+	listed := approved
+	listed.Notes = ""
+	assert.Equal(t, []types.Recipient{listed}, listRecipients(t, e, "/recipients"))
+
+	// A threshold change keeps the approval, a rename sends it back for
+	// review, and the operator's metadata survives both.
+	require.Equal(t, http.StatusOK, recipient.postRegister(t, e, `{"name":"Legal Team","threshold":4}`).Code)
+	assert.Len(t, listRecipients(t, e, "/recipients"), 1)
+	require.Equal(t, http.StatusOK, recipient.postRegister(t, e, `{"name":"Impostor Desk"}`).Code)
+	assert.Empty(t, listRecipients(t, e, "/recipients"))
+	assert.Equal(t, []types.Recipient{{
+		Name:         "Impostor Desk",
+		PublicKey:    encodedKey,
+		Status:       types.RecipientPending,
+		Organization: "Example News",
+		Contact:      "legal@example.org",
+		Notes:        "verified by phone",
+	}}, listRecipients(t, e, "/admin/recipients"))
+
+	// Suspending delists a recipient.
+	require.Equal(t, http.StatusOK, adminRequest(e, http.MethodPut, "/admin/recipients/"+recipient.urlKey(), `{"status":"approved"}`).Code)
+	assert.Len(t, listRecipients(t, e, "/recipients"), 1)
+	require.Equal(t, http.StatusOK, adminRequest(e, http.MethodPut, "/admin/recipients/"+recipient.urlKey(), `{"status":"suspended"}`).Code)
+	assert.Empty(t, listRecipients(t, e, "/recipients"))
+}
+
+func TestAdminAuth(t *testing.T) {
+	e, s := setupAdminRouter()
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	for _, authorization := range []string{"", "Bearer wrong", testAdminToken} {
+		req := httptest.NewRequest(http.MethodPut, "/admin/recipients/"+recipient.urlKey(), strings.NewReader(`{"status":"approved"}`))
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+	}
+
+	// Without a token the API is not served at all.
+	e, _ = setupTestRouter()
+	assert.Equal(t, http.StatusNotFound, adminRequest(e, http.MethodGet, "/admin/recipients", "").Code)
+}
+
+func TestAdminRejectsInvalidReview(t *testing.T) {
+	e, s := setupAdminRouter()
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	assert.Equal(t, http.StatusBadRequest, adminRequest(e, http.MethodPut, "/admin/recipients/"+recipient.urlKey(), `{"status":"trusted"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, adminRequest(e, http.MethodGet, "/admin/recipients?status=trusted", "").Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest(e, http.MethodPut, "/admin/recipients/"+newTestRecipient().urlKey(), `{"status":"approved"}`).Code)
+
+	// Recipients registered before review existed list as pending.
+	assert.Equal(t, types.RecipientPending, listRecipients(t, e, "/admin/recipients?status=pending")[0].Status)
+}
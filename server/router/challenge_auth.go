@@ -7,37 +7,56 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 	"strings"
+	"time"
 
+	"github.com/berkmancenter/rendezvous-point/store"
 	"github.com/berkmancenter/rendezvous-point/types"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/curve25519"
 	"golang.org/x/crypto/hkdf"
 )
 
-func challengeAuth(next echo.HandlerFunc) echo.HandlerFunc {
+const (
+	// DefaultChallengeTTL is how long a recipient has to answer an inbox
+	// challenge.
+	DefaultChallengeTTL = 2 * time.Minute
+
+	defaultMaxChallenges         = 5
+	defaultMaxRegisterChallenges = 5
+
+	// registerChallengePrefix sets a key's registration challenges apart
+	// from its inbox challenges. No URL-safe public key contains a slash.
+	registerChallengePrefix = "register/"
+)
+
+// challengeAuth accepts an answered challenge ("Bearer") only. Inbox
+// sessions are accepted by sessionAuth, on the routes that read the inbox.
+func (s *server) challengeAuth(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		authHeader := c.Request().Header.Get("Authorization")
+		if strings.HasPrefix(authHeader, "Session ") {
+			return errInvalidAuthorization.withMessage("sessions only authenticate reading and deleting inbox shares")
+		}
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			return echo.NewHTTPError(http.StatusUnauthorized, "invalid auth scheme")
+			return errInvalidAuthorization.withMessage("invalid auth scheme")
 		}
 
 		b64 := strings.TrimPrefix(authHeader, "Bearer ")
 		payload, err := base64.StdEncoding.DecodeString(b64)
 		if err != nil {
-			return echo.NewHTTPError(http.StatusUnauthorized, "invalid base64")
+			return errInvalidAuthorization.withMessage("invalid base64")
 		}
 
 		var auth types.ChallengeAuth
 		if err := json.Unmarshal(payload, &auth); err != nil {
-			return echo.NewHTTPError(http.StatusUnauthorized, "invalid json")
+			return errInvalidAuthorization.withMessage("invalid json")
 		}
 
-		err = verifyChallenge(c.Param("key"), auth.EncryptedToken, auth.Nonce)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusUnauthorized, err)
+		if err := s.verifyChallenge(c.Param("key"), auth.EncryptedToken, auth.Nonce); err != nil {
+			return err
 		}
 
 		return next(c)
@@ -64,42 +83,40 @@ func newChallenge() (*types.Challenge, error) {
 		EphemeralPrivateKey: ephemeralPrivateKey,
 		EphemeralPublicKey:  ephemeralPublicKey,
 		Nonce:               nonce,
+		IssuedAt:            time.Now(),
 	}, nil
 }
 
-func verifyChallenge(publicKey string, encryptedToken string, nonce string) error {
-	challengesMu.Lock()
-	defer challengesMu.Unlock()
-
-	recipientChallenges, ok := challenges[publicKey]
-	if !ok {
-		return fmt.Errorf("no challenges for public key")
+// verifyChallenge takes the challenge for nonce, from publicKey's inbox
+// challenges or else its registration challenges, failing with an apiError
+// unless encryptedToken answers it.
+func (s *server) verifyChallenge(publicKey string, encryptedToken string, nonce string) error {
+	challenge, err := s.store.TakeChallenge(publicKey, nonce)
+	if errors.Is(err, store.ErrNotFound) {
+		challenge, err = s.store.TakeChallenge(registerChallengePrefix+publicKey, nonce)
 	}
-
-	challenge, ok := recipientChallenges[nonce]
-	if !ok {
-		return fmt.Errorf("no challenge for nonce")
+	if errors.Is(err, store.ErrNotFound) {
+		return errChallengeFailed.withMessage("no challenge for nonce")
+	} else if err != nil {
+		return errInternal.withMessage("failed to load challenge")
+	}
+	if time.Since(challenge.IssuedAt) > s.challengeTTL {
+		return errChallengeExpired
 	}
 
 	encryptedTokenBytes, err := base64.StdEncoding.DecodeString(encryptedToken)
 	if err != nil {
-		return fmt.Errorf("invalid base64")
+		return errChallengeFailed.withMessage("invalid base64")
 	}
 
 	peerKeyBytes, err := base64.RawURLEncoding.DecodeString(publicKey)
 	if err != nil {
-		return fmt.Errorf("invalid public key")
+		return errInvalidKey.withMessage("invalid public key")
 	}
 
 	decrypted, err := aesGCMOpen(encryptedTokenBytes, peerKeyBytes, challenge.EphemeralPrivateKey)
 	if err != nil || string(decrypted) != string(challenge.Token) {
-		return fmt.Errorf("challenge failed")
-	}
-
-	delete(recipientChallenges, nonce)
-
-	if len(recipientChallenges) == 0 {
-		delete(challenges, publicKey)
+		return errChallengeFailed
 	}
 
 	return nil
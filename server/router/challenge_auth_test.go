@@ -6,15 +6,21 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/berkmancenter/rendezvous-point/store"
 	"github.com/berkmancenter/rendezvous-point/types"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/curve25519"
 )
 
 func TestVerifyChallenge_Success(t *testing.T) {
+	s := newServer(Config{})
+
 	var peerPrivateKey [32]byte
 	cryptoRand.Read(peerPrivateKey[:])
 
@@ -27,23 +33,27 @@ func TestVerifyChallenge_Success(t *testing.T) {
 
 	encodedNonce := base64.StdEncoding.Strict().EncodeToString(challenge.Nonce)
 
-	challenges[peerPublicKeyString] = map[string]types.Challenge{encodedNonce: *challenge}
+	s.store.PutChallenge(peerPublicKeyString, encodedNonce, *challenge, s.maxChallenges, time.Time{})
 
 	encryptedToken, err := encryptedToken(challenge.Token, peerPrivateKey[:], challenge.EphemeralPublicKey[:])
 	assert.NoError(t, err)
 
 	// Test verification
-	err = verifyChallenge(peerPublicKeyString, *encryptedToken, encodedNonce)
+	err = s.verifyChallenge(peerPublicKeyString, *encryptedToken, encodedNonce)
 	assert.NoError(t, err)
 }
 
 func TestVerifyChallenge_NoChallenge(t *testing.T) {
-	err := verifyChallenge("somekey", "!!!!", "nonce")
+	s := newServer(Config{})
+
+	err := s.verifyChallenge("somekey", "!!!!", "nonce")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no challenge")
 }
 
 func TestVerifyChallenge_WrongNonce(t *testing.T) {
+	s := newServer(Config{})
+
 	var peerPrivateKey [32]byte
 	cryptoRand.Read(peerPrivateKey[:])
 
@@ -54,17 +64,136 @@ func TestVerifyChallenge_WrongNonce(t *testing.T) {
 	encodedNonce := base64.StdEncoding.Strict().EncodeToString(challenge.Nonce)
 
 	// Store challenge under one nonce
-	challengesMu.Lock()
-	challenges[peerPublicKeyString] = map[string]types.Challenge{encodedNonce: *challenge}
-	challengesMu.Unlock()
+	s.store.PutChallenge(peerPublicKeyString, encodedNonce, *challenge, s.maxChallenges, time.Time{})
 
 	// Use a different nonce
-	err := verifyChallenge(peerPublicKeyString, "!!!", "invalid-nonce")
+	err := s.verifyChallenge(peerPublicKeyString, "!!!", "invalid-nonce")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "no challenge for nonce")
 }
 
+func TestVerifyChallenge_Expired(t *testing.T) {
+	s := newServer(Config{ChallengeTTL: time.Minute})
+
+	var peerPrivateKey [32]byte
+	cryptoRand.Read(peerPrivateKey[:])
+	peerPublicKey, _ := curve25519.X25519(peerPrivateKey[:], curve25519.Basepoint)
+	peerPublicKeyString := base64.RawURLEncoding.EncodeToString(peerPublicKey)
+
+	challenge, _ := newChallenge()
+	challenge.IssuedAt = time.Now().Add(-2 * time.Minute)
+	encodedNonce := base64.StdEncoding.Strict().EncodeToString(challenge.Nonce)
+	s.store.PutChallenge(peerPublicKeyString, encodedNonce, *challenge, s.maxChallenges, time.Time{})
+
+	token, _ := encryptedToken(challenge.Token, peerPrivateKey[:], challenge.EphemeralPublicKey[:])
+	err := s.verifyChallenge(peerPublicKeyString, *token, encodedNonce)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "expired")
+}
+
+func TestInboxChallenge_Unregistered(t *testing.T) {
+	e, _ := setupTestRouter()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/inbox/"+newTestRecipient().urlKey()+"/challenge", nil)
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestInboxChallenge_Limit(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, MaxChallenges: 2})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	requestChallenge := func(r testRecipient) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/inbox/"+r.urlKey()+"/challenge", nil)
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, requestChallenge(recipient))
+	assert.Equal(t, http.StatusOK, requestChallenge(recipient))
+	assert.Equal(t, http.StatusTooManyRequests, requestChallenge(recipient))
+
+	// The cap is per recipient.
+	other := newTestRecipient()
+	other.register(t, s)
+	assert.Equal(t, http.StatusOK, requestChallenge(other))
+}
+
+func TestInboxChallenge_ExpiredDoNotCount(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, MaxChallenges: 2, ChallengeTTL: time.Minute})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	// The cap is full of challenges the sweeper has not purged yet.
+	stale := types.Challenge{IssuedAt: time.Now().Add(-2 * time.Minute)}
+	require.NoError(t, s.PutChallenge(recipient.urlKey(), "stale-1", stale, 2, time.Time{}))
+	require.NoError(t, s.PutChallenge(recipient.urlKey(), "stale-2", stale, 2, time.Time{}))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/inbox/"+recipient.urlKey()+"/challenge", nil)
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestRegisterChallenge_Pool(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, MaxChallenges: 1, MaxRegisterChallenges: 2})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	requestChallenge := func(path string) int {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// Registration challenges do not take a recipient's inbox slots.
+	assert.Equal(t, http.StatusOK, requestChallenge("/register/"+recipient.urlKey()+"/challenge"))
+	assert.Equal(t, http.StatusOK, requestChallenge("/inbox/"+recipient.urlKey()+"/challenge"))
+
+	// Each key has its own cap, so filling one key's slots leaves every
+	// other key free to register or rotate.
+	flooded := newTestRecipient()
+	for range 2 {
+		assert.Equal(t, http.StatusOK, requestChallenge("/register/"+flooded.urlKey()+"/challenge"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, requestChallenge("/register/"+flooded.urlKey()+"/challenge"))
+	assert.Equal(t, http.StatusOK, requestChallenge("/register/"+newTestRecipient().urlKey()+"/challenge"))
+
+	from := newTestRecipient()
+	from.register(t, s)
+	assert.Equal(t, http.StatusOK, rotate(t, e, from, newTestRecipient()).Code)
+}
+
+func TestInboxChallenge_NonCanonicalKey(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	// The last character of a 32 byte key carries two unused bits.
+	key := []byte(recipient.urlKey())
+	key[len(key)-1] = base64URLAlphabet[strings.IndexByte(base64URLAlphabet, key[len(key)-1])^1]
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/inbox/"+string(key)+"/challenge", nil)
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+const base64URLAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
 func TestVerifyChallenge_BadToken(t *testing.T) {
+	s := newServer(Config{})
+
 	var peerPrivateKey [32]byte
 	cryptoRand.Read(peerPrivateKey[:])
 
@@ -74,17 +203,17 @@ func TestVerifyChallenge_BadToken(t *testing.T) {
 	challenge, _ := newChallenge()
 	encodedNonce := base64.StdEncoding.Strict().EncodeToString(challenge.Nonce)
 
-	challengesMu.Lock()
-	challenges[peerPublicKeyString] = map[string]types.Challenge{encodedNonce: *challenge}
-	challengesMu.Unlock()
+	s.store.PutChallenge(peerPublicKeyString, encodedNonce, *challenge, s.maxChallenges, time.Time{})
 
 	// Tampered token
-	err := verifyChallenge(peerPublicKeyString, "badtoken==", encodedNonce)
+	err := s.verifyChallenge(peerPublicKeyString, "badtoken==", encodedNonce)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid base64")
 }
 
 func TestChallengeAuth_Success(t *testing.T) {
+	s := newServer(Config{})
+
 	var peerPrivateKey [32]byte
 	cryptoRand.Read(peerPrivateKey[:])
 	peerPublicKey, _ := curve25519.X25519(peerPrivateKey[:], curve25519.Basepoint)
@@ -99,9 +228,7 @@ func TestChallengeAuth_Success(t *testing.T) {
 	jsonPayload := fmt.Sprintf(`{"nonce":"%s","encryptedToken":"%s"}`, encodedNonce, *token)
 	authHeader := "Bearer " + base64.StdEncoding.EncodeToString([]byte(jsonPayload))
 
-	challengesMu.Lock()
-	challenges[peerPublicKeyString] = map[string]types.Challenge{encodedNonce: *challenge}
-	challengesMu.Unlock()
+	s.store.PutChallenge(peerPublicKeyString, encodedNonce, *challenge, s.maxChallenges, time.Time{})
 
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/inbox/"+peerPublicKeyString, nil)
@@ -111,7 +238,7 @@ func TestChallengeAuth_Success(t *testing.T) {
 	c.SetParamNames("key")
 	c.SetParamValues(peerPublicKeyString)
 
-	h := challengeAuth(func(c echo.Context) error {
+	h := s.challengeAuth(func(c echo.Context) error {
 		return c.String(http.StatusOK, "pass")
 	})
 
@@ -122,6 +249,8 @@ func TestChallengeAuth_Success(t *testing.T) {
 }
 
 func TestChallengeAuth_MalformedBase64(t *testing.T) {
+	s := newServer(Config{})
+
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/inbox/somekey", nil)
 	req.Header.Set("Authorization", "Bearer !!!not-base64")
@@ -130,17 +259,20 @@ func TestChallengeAuth_MalformedBase64(t *testing.T) {
 	c.SetParamNames("key")
 	c.SetParamValues("somekey")
 
-	err := challengeAuth(func(c echo.Context) error {
+	err := s.challengeAuth(func(c echo.Context) error {
 		return c.String(http.StatusOK, "pass")
 	})(c)
 
-	httpErr, ok := err.(*echo.HTTPError)
-	assert.True(t, ok)
-	assert.Equal(t, http.StatusUnauthorized, httpErr.Code)
-	assert.Contains(t, httpErr.Message.(string), "invalid base64")
+	apiErr, ok := err.(*apiError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.status)
+	assert.Equal(t, types.ErrorInvalidAuthorization, apiErr.code)
+	assert.Contains(t, apiErr.message, "invalid base64")
 }
 
 func TestChallengeAuth_MalformedJSON(t *testing.T) {
+	s := newServer(Config{})
+
 	badJSON := base64.StdEncoding.EncodeToString([]byte("{not json}"))
 
 	e := echo.New()
@@ -151,12 +283,13 @@ func TestChallengeAuth_MalformedJSON(t *testing.T) {
 	c.SetParamNames("key")
 	c.SetParamValues("somekey")
 
-	err := challengeAuth(func(c echo.Context) error {
+	err := s.challengeAuth(func(c echo.Context) error {
 		return c.String(http.StatusOK, "pass")
 	})(c)
 
-	httpErr, ok := err.(*echo.HTTPError)
-	assert.True(t, ok)
-	assert.Equal(t, http.StatusUnauthorized, httpErr.Code)
-	assert.Contains(t, httpErr.Message.(string), "invalid json")
+	apiErr, ok := err.(*apiError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.status)
+	assert.Equal(t, types.ErrorInvalidAuthorization, apiErr.code)
+	assert.Contains(t, apiErr.message, "invalid json")
 }
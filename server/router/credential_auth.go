@@ -1,49 +1,138 @@
 package router
 
 import (
-	"net/http"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
-	"github.com/openrdap/rdap"
 )
 
-func lookupOrgByIP(ip string) (*string, error) {
-	client := &rdap.Client{}
-	result, err := client.QueryIP(ip)
+// credentialLifetime is how long an issued credential can authorize
+// disclosures.
+const credentialLifetime = 48 * time.Hour
+
+// credential is what a verified JWT or token authorizes.
+type credential struct {
+	org     string
+	id      string
+	expires time.Time
+	// scope is where the credential is spent. Nil means the recipient
+	// disclosed to.
+	scope []byte
+}
+
+// organization resolves the canonical name of the requester's
+// organization.
+func (s *server) organization(c echo.Context) (string, error) {
+	addr, err := netip.ParseAddr(c.RealIP())
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	if len(result.Entities) > 0 {
-		realName := result.Entities[0].VCard.Name()
-		return &realName, nil
-	} else {
-		return &result.Name, nil
+	organization, err := s.resolver.Resolve(addr.Unmap())
+	if err != nil {
+		return "", err
 	}
+
+	return s.aliases.Canonical(organization), nil
 }
 
-func newCredential(c echo.Context) (map[string]string, error) {
-	ip := c.RealIP()
-	organization, err := lookupOrgByIP(ip)
+func (s *server) newCredential(c echo.Context) (map[string]string, error) {
+	name, err := s.organization(c)
 	if err != nil {
-		return nil, c.String(http.StatusInternalServerError, "could not lookup IP organization")
+		return nil, errOrganizationUnknown
+	}
+
+	jti, err := newCredentialID()
+	if err != nil {
+		return nil, errInternal.withMessage("could not sign token")
 	}
 
 	claims := jwt.MapClaims{
-		"org": organization,
-		"exp": time.Now().Add(48 * time.Hour).Unix(),
+		"jti": jti,
+		"org": name,
+		"exp": time.Now().Add(credentialLifetime).Unix(),
 		"iat": time.Now().Unix(),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
-	signedToken, err := token.SignedString(signingKey)
+	signedToken, err := s.keys.Sign(claims)
 	if err != nil {
-		return nil, c.String(http.StatusInternalServerError, "could not sign token")
+		return nil, errInternal.withMessage("could not sign token")
 	}
 
 	return map[string]string{
-		"organization": *organization,
+		"organization": name,
 		"credential":   signedToken,
 	}, nil
 }
+
+// newCredentialID returns a random ID for a credential, so the server can
+// refuse a second disclosure to the same recipient under it.
+func newCredentialID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id), nil
+}
+
+// credentialAuth accepts a JWT credential ("Bearer") or a blind signed
+// token ("Token"), setting "credential" on the context.
+func (s *server) credentialAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	bearer := echojwt.WithConfig(echojwt.Config{
+		KeyFunc: s.keys.KeyFunc,
+		ErrorHandler: func(c echo.Context, err error) error {
+			var extraction *echojwt.TokenExtractionError
+			if errors.As(err, &extraction) {
+				return errMissingCredential
+			}
+			return errInvalidCredential
+		},
+	})(func(c echo.Context) error {
+		claims := c.Get("user").(*jwt.Token).Claims.(jwt.MapClaims)
+		cred, err := jwtCredential(claims)
+		if err != nil {
+			return errInvalidCredential.withMessage(err.Error())
+		}
+		c.Set("credential", cred)
+		return next(c)
+	})
+
+	return func(c echo.Context) error {
+		authHeader := c.Request().Header.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Token ") {
+			return bearer(c)
+		}
+
+		cred, err := s.verifyToken(strings.TrimPrefix(authHeader, "Token "))
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			return apiErr
+		} else if err != nil {
+			return errInvalidCredential.withMessage(err.Error())
+		}
+		c.Set("credential", cred)
+		return next(c)
+	}
+}
+
+func jwtCredential(claims jwt.MapClaims) (*credential, error) {
+	org, _ := claims["org"].(string)
+	if org == "" {
+		return nil, fmt.Errorf("credential has no organization")
+	}
+
+	jti, _ := claims["jti"].(string)
+	expires, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || expires == nil {
+		return nil, fmt.Errorf("credential is not single use")
+	}
+
+	return &credential{org: org, id: jti, expires: expires.Time}, nil
+}
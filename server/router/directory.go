@@ -0,0 +1,33 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/directory"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// getDirectory signs the approved recipients with the epoch they were
+// first published in.
+func (s *server) getDirectory(c echo.Context) error {
+	recipients, err := s.approvedRecipients()
+	if err != nil {
+		return errInternal.withMessage("failed to load recipients")
+	}
+
+	epoch, err := s.store.AdvanceDirectory(directory.Hash(recipients))
+	if err != nil {
+		return errInternal.withMessage("failed to record directory")
+	}
+	claims := directory.New(epoch, recipients)
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+
+	signed, err := s.logKey.Sign(claims)
+	if err != nil {
+		return errInternal.withMessage("could not sign directory")
+	}
+	return c.JSON(http.StatusOK, types.DirectoryResponse{Directory: signed})
+}
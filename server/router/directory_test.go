@@ -0,0 +1,99 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/directory"
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/translog"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestDirectory(t *testing.T, e *echo.Echo) *directory.Claims {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/directory", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response types.DirectoryResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	var jwks types.JWKS
+	getTestJSON(t, e, "/.well-known/log-jwks.json", &jwks)
+	claims, err := directory.Parse(response.Directory, directory.KeyFunc(jwks))
+	require.NoError(t, err)
+	return claims
+}
+
+func TestDirectory(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Keys: keys})
+
+	empty := getTestDirectory(t, e)
+	assert.Equal(t, uint64(1), empty.Epoch)
+	assert.Empty(t, empty.Recipients)
+	assert.NotNil(t, empty.IssuedAt)
+
+	approved := types.Recipient{
+		Name:      "Legal Team",
+		PublicKey: base64.StdEncoding.EncodeToString(newTestRecipient().publicKey),
+		Status:    types.RecipientApproved,
+		Notes:     "verified by phone",
+	}
+	require.NoError(t, s.PutRecipient(approved))
+	require.NoError(t, s.PutRecipient(types.Recipient{Name: "Pending", PublicKey: "pending", Status: types.RecipientPending}))
+
+	listed := getTestDirectory(t, e)
+	assert.Equal(t, uint64(2), listed.Epoch)
+	approved.Notes = ""
+	assert.Equal(t, []types.Recipient{approved}, listed.Recipients)
+	assert.NoError(t, directory.Check(empty, listed))
+
+	// The epoch only moves when the recipients do.
+	again := getTestDirectory(t, e)
+	assert.Equal(t, listed.Epoch, again.Epoch)
+	assert.Equal(t, listed.Hash, again.Hash)
+
+	require.NoError(t, s.DeleteRecipient(approved.PublicKey))
+	assert.Equal(t, uint64(3), getTestDirectory(t, e).Epoch)
+}
+
+func TestDirectory_OutlivesCredentialKeys(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	e := echo.New()
+	RegisterRoutes(e, Config{Keys: keys})
+
+	var response types.DirectoryResponse
+	getTestJSON(t, e, "/directory", &response)
+	var head types.TreeHeadResponse
+	getTestJSON(t, e, "/log/head", &head)
+
+	// Credential keys rotate out of the JWKS, but the directory and tree
+	// head were signed with the log key, which stays published.
+	for range 3 {
+		_, err := keys.Rotate()
+		require.NoError(t, err)
+	}
+	var jwks, logJWKS types.JWKS
+	getTestJSON(t, e, "/.well-known/jwks.json", &jwks)
+	getTestJSON(t, e, "/.well-known/log-jwks.json", &logJWKS)
+	require.Len(t, logJWKS.Keys, 1)
+	for _, jwk := range jwks.Keys {
+		assert.NotEqual(t, logJWKS.Keys[0].KeyID, jwk.KeyID)
+	}
+
+	_, err := directory.Parse(response.Directory, directory.KeyFunc(logJWKS))
+	assert.NoError(t, err)
+	_, err = translog.ParseTreeHead(head.TreeHead, directory.KeyFunc(logJWKS))
+	assert.NoError(t, err)
+	_, err = directory.Parse(response.Directory, directory.KeyFunc(jwks))
+	assert.Error(t, err)
+}
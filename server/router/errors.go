@@ -0,0 +1,127 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+)
+
+// apiError is a failure reported to clients as a types.ErrorResponse.
+type apiError struct {
+	status    int
+	code      types.ErrorCode
+	message   string
+	retryable bool
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// withMessage returns a copy of e with a more specific message.
+func (e *apiError) withMessage(message string) *apiError {
+	narrowed := *e
+	narrowed.message = message
+	return &narrowed
+}
+
+// The catalog of failures. Handlers narrow the message with withMessage
+// but never change the status or code.
+var (
+	errInvalidBody          = &apiError{http.StatusBadRequest, types.ErrorInvalidBody, "invalid body", false}
+	errInvalidKey           = &apiError{http.StatusBadRequest, types.ErrorInvalidKey, "invalid key encoding", false}
+	errInvalidParameter     = &apiError{http.StatusBadRequest, types.ErrorInvalidParameter, "invalid parameter", false}
+	errKeyMismatch          = &apiError{http.StatusBadRequest, types.ErrorKeyMismatch, "public key does not match", false}
+	errNotRegistered        = &apiError{http.StatusNotFound, types.ErrorNotRegistered, "recipient not registered", false}
+	errAlreadyRegistered    = &apiError{http.StatusConflict, types.ErrorAlreadyRegistered, "key is already registered", false}
+	errInvalidAuthorization = &apiError{http.StatusUnauthorized, types.ErrorInvalidAuthorization, "invalid authorization", false}
+	errChallengeFailed      = &apiError{http.StatusUnauthorized, types.ErrorChallengeFailed, "challenge failed", false}
+	errChallengeExpired     = &apiError{http.StatusUnauthorized, types.ErrorChallengeExpired, "challenge expired", false}
+	errTooManyChallenges    = &apiError{http.StatusTooManyRequests, types.ErrorTooManyChallenges, "too many outstanding challenges", true}
+	errInvalidSession       = &apiError{http.StatusUnauthorized, types.ErrorInvalidSession, "invalid session", false}
+	errUnsupportedProtocol  = &apiError{http.StatusBadRequest, types.ErrorUnsupportedProtocol, "unsupported protocol", false}
+	errInvalidShare         = &apiError{http.StatusBadRequest, types.ErrorInvalidShare, "invalid share", false}
+	errMissingCredential    = &apiError{http.StatusBadRequest, types.ErrorMissingCredential, "missing or malformed credential", false}
+	errInvalidCredential    = &apiError{http.StatusUnauthorized, types.ErrorInvalidCredential, "invalid credential", false}
+	errCredentialUsed       = &apiError{http.StatusConflict, types.ErrorCredentialUsed, "credential already used", false}
+	errTokenKeyRotated      = &apiError{http.StatusConflict, types.ErrorTokenKeyRotated, "token key has rotated", true}
+	errTooManyTokenKeys     = &apiError{http.StatusServiceUnavailable, types.ErrorTooManyTokenKeys, "too many organizations issued token keys this epoch", true}
+	errOrganizationUnknown  = &apiError{http.StatusInternalServerError, types.ErrorOrganizationUnknown, "could not lookup IP organization", true}
+	errNotFound             = &apiError{http.StatusNotFound, types.ErrorNotFound, "not found", false}
+	errMethodNotAllowed     = &apiError{http.StatusMethodNotAllowed, types.ErrorMethodNotAllowed, "method not allowed", false}
+	errBodyTooLarge         = &apiError{http.StatusRequestEntityTooLarge, types.ErrorBodyTooLarge, "request body too large", false}
+	errInternal             = &apiError{http.StatusInternalServerError, types.ErrorInternal, "internal error", true}
+)
+
+// handleError writes every error returned by a handler or middleware,
+// including Echo's own, as a types.ErrorResponse.
+func handleError(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		apiErr = fromHTTPError(err)
+	}
+
+	response := types.ErrorResponse{
+		Code:      apiErr.code,
+		Message:   apiErr.message,
+		Retryable: apiErr.retryable,
+	}
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.status)
+	} else {
+		err = c.JSON(apiErr.status, response)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// fromHTTPError maps errors raised outside this package, such as by
+// routing or middleware, onto the catalog. A status the catalog has no
+// entry for keeps its status, with its status text as the code.
+func fromHTTPError(err error) *apiError {
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		return errInternal
+	}
+
+	var base *apiError
+	switch he.Code {
+	case http.StatusNotFound:
+		base = errNotFound
+	case http.StatusMethodNotAllowed:
+		base = errMethodNotAllowed
+	case http.StatusRequestEntityTooLarge:
+		base = errBodyTooLarge
+	case http.StatusUnauthorized:
+		base = errInvalidAuthorization
+	case http.StatusBadRequest:
+		base = errInvalidParameter
+	case http.StatusInternalServerError:
+		base = errInternal
+	default:
+		text := http.StatusText(he.Code)
+		if text == "" {
+			return errInternal
+		}
+		retryable := he.Code == http.StatusTooManyRequests || he.Code >= http.StatusInternalServerError
+		code := types.ErrorCode(strings.ReplaceAll(strings.ToLower(text), " ", "_"))
+		base = &apiError{he.Code, code, strings.ToLower(text), retryable}
+	}
+	if message, ok := he.Message.(string); ok {
+		return base.withMessage(message)
+	}
+	return base
+}
+
+// statusOK reports success for requests that return nothing else.
+func statusOK(c echo.Context) error {
+	return c.JSON(http.StatusOK, types.StatusResponse{Status: "ok"})
+}
@@ -0,0 +1,273 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails the named methods of the store it wraps.
+type failingStore struct {
+	store.Store
+	fail map[string]bool
+}
+
+var errStoreDown = errors.New("store unavailable")
+
+func (f failingStore) Shares(recipient []byte) ([]store.Share, error) {
+	if f.fail["Shares"] {
+		return nil, errStoreDown
+	}
+	return f.Store.Shares(recipient)
+}
+
+func (f failingStore) Recipient(publicKey string) (*types.Recipient, error) {
+	if f.fail["Recipient"] {
+		return nil, errStoreDown
+	}
+	return f.Store.Recipient(publicKey)
+}
+
+func (f failingStore) PutShare(recipient []byte, share store.Share) error {
+	if f.fail["PutShare"] {
+		return errStoreDown
+	}
+	return f.Store.PutShare(recipient, share)
+}
+
+func (f failingStore) SpendCredential(scope []byte, id string, expires time.Time) error {
+	if f.fail["SpendCredential"] {
+		return errStoreDown
+	}
+	return f.Store.SpendCredential(scope, id, expires)
+}
+
+func assertErrorResponse(t *testing.T, rec *httptest.ResponseRecorder, status int, code types.ErrorCode) {
+	t.Helper()
+	assert.Equal(t, status, rec.Code, rec.Body.String())
+
+	var response types.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+	assert.Equal(t, code, response.Code)
+	assert.NotEmpty(t, response.Message)
+}
+
+// testChallengeAuth stores a challenge for r issued at issuedAt and
+// returns an Authorization header answering it with answer, or with the
+// right token if answer is nil.
+func testChallengeAuth(t *testing.T, s store.Store, r testRecipient, issuedAt time.Time, answer []byte) string {
+	challenge, err := newChallenge()
+	require.NoError(t, err)
+	challenge.IssuedAt = issuedAt
+	nonce := base64.StdEncoding.EncodeToString(challenge.Nonce)
+	require.NoError(t, s.PutChallenge(r.urlKey(), nonce, *challenge, defaultMaxChallenges, time.Time{}))
+
+	if answer == nil {
+		answer = challenge.Token
+	}
+	token, err := encryptedToken(answer, r.privateKey, challenge.EphemeralPublicKey)
+	require.NoError(t, err)
+	payload, _ := json.Marshal(types.ChallengeAuth{Nonce: nonce, EncryptedToken: *token})
+	return "Bearer " + base64.StdEncoding.EncodeToString(payload)
+}
+
+func TestErrors_Disclose(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	recipient := newTestRecipient()
+	validBody := func() string {
+		body, _ := json.Marshal(types.DisclosureRequest{ID: "id", Recipient: base64.StdEncoding.EncodeToString(recipient.publicKey), VerifiableShare: newTestShare("id")})
+		return string(body)
+	}
+	noJTI, _ := keys.Sign(jwt.MapClaims{"org": "OrgA", "exp": time.Now().Add(time.Hour).Unix()})
+	other, _ := keyring.New(keyring.Options{})
+	foreign, _ := other.Sign(jwt.MapClaims{"jti": "id", "org": "OrgA", "exp": time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name          string
+		fail          string
+		authorization string
+		body          string
+		status        int
+		code          types.ErrorCode
+	}{
+		{"missing credential", "", "", validBody(), http.StatusBadRequest, types.ErrorMissingCredential},
+		{"unknown signing key", "", "Bearer " + foreign, validBody(), http.StatusUnauthorized, types.ErrorInvalidCredential},
+		{"malformed credential", "", "Bearer not-a-jwt", validBody(), http.StatusUnauthorized, types.ErrorInvalidCredential},
+		{"credential without ID", "", "Bearer " + noJTI, validBody(), http.StatusUnauthorized, types.ErrorInvalidCredential},
+		{"malformed token", "", "Token !!!", validBody(), http.StatusUnauthorized, types.ErrorInvalidCredential},
+		{"invalid body", "", "", "{", http.StatusBadRequest, types.ErrorInvalidBody},
+		{"invalid recipient", "", "", `{"id":"id","recipient":"!!!"}`, http.StatusBadRequest, types.ErrorInvalidKey},
+		{"body too large", "", "", `{"id":"` + strings.Repeat("a", 4096) + `"}`, http.StatusRequestEntityTooLarge, types.ErrorBodyTooLarge},
+		{"spend fails", "SpendCredential", "", validBody(), http.StatusInternalServerError, types.ErrorInternal},
+		{"store fails", "PutShare", "", validBody(), http.StatusInternalServerError, types.ErrorInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			RegisterRoutes(e, Config{Store: failingStore{store.NewMemory(), map[string]bool{tt.fail: true}}, Keys: keys})
+
+			authorization := tt.authorization
+			if authorization == "" && tt.code != types.ErrorMissingCredential {
+				authorization = "Bearer " + signTestCredential(t, keys, "OrgA")
+			}
+			req := httptest.NewRequest(http.MethodPost, "/disclose", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", authorization)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assertErrorResponse(t, rec, tt.status, tt.code)
+		})
+	}
+
+	t.Run("credential used", func(t *testing.T) {
+		e := echo.New()
+		RegisterRoutes(e, Config{Keys: keys})
+		credential := signTestCredential(t, keys, "OrgA")
+		require.Equal(t, http.StatusOK, postTestDisclosure(e, credential, recipient.publicKey, "id-1").Code)
+		assertErrorResponse(t, postTestDisclosure(e, credential, recipient.publicKey, "id-2"), http.StatusConflict, types.ErrorCredentialUsed)
+	})
+}
+
+func TestErrors_ChallengeAuth(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	recipient, other := newTestRecipient(), newTestRecipient()
+
+	tests := []struct {
+		name          string
+		authorization func(s store.Store) string
+		status        int
+		code          types.ErrorCode
+	}{
+		{"missing", func(store.Store) string { return "" }, http.StatusUnauthorized, types.ErrorInvalidAuthorization},
+		{"unknown scheme", func(store.Store) string { return "Basic abc" }, http.StatusUnauthorized, types.ErrorInvalidAuthorization},
+		{"malformed base64", func(store.Store) string { return "Bearer !!!" }, http.StatusUnauthorized, types.ErrorInvalidAuthorization},
+		{"malformed json", func(store.Store) string {
+			return "Bearer " + base64.StdEncoding.EncodeToString([]byte("{"))
+		}, http.StatusUnauthorized, types.ErrorInvalidAuthorization},
+		{"unknown nonce", func(store.Store) string {
+			payload, _ := json.Marshal(types.ChallengeAuth{Nonce: "nonce", EncryptedToken: "token"})
+			return "Bearer " + base64.StdEncoding.EncodeToString(payload)
+		}, http.StatusUnauthorized, types.ErrorChallengeFailed},
+		{"wrong answer", func(s store.Store) string {
+			return testChallengeAuth(t, s, recipient, time.Now(), []byte("wrong"))
+		}, http.StatusUnauthorized, types.ErrorChallengeFailed},
+		{"expired", func(s store.Store) string {
+			return testChallengeAuth(t, s, recipient, time.Now().Add(-time.Hour), nil)
+		}, http.StatusUnauthorized, types.ErrorChallengeExpired},
+		{"invalid session", func(store.Store) string { return "Session not-a-jwt" }, http.StatusUnauthorized, types.ErrorInvalidSession},
+		{"session for another key", func(store.Store) string {
+			session, _ := keys.Sign(jwt.MapClaims{"sub": other.urlKey(), "scope": sessionScope, "exp": time.Now().Add(time.Minute).Unix()})
+			return "Session " + session
+		}, http.StatusUnauthorized, types.ErrorInvalidSession},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := store.NewMemory()
+			e := echo.New()
+			RegisterRoutes(e, Config{Store: s, Keys: keys})
+			recipient.register(t, s)
+
+			req := httptest.NewRequest(http.MethodGet, "/inbox/"+recipient.urlKey(), nil)
+			req.Header.Set("Authorization", tt.authorization(s))
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assertErrorResponse(t, rec, tt.status, tt.code)
+		})
+	}
+}
+
+func TestErrors_Inbox(t *testing.T) {
+	recipient := newTestRecipient()
+
+	for _, method := range []string{"Shares", "Recipient"} {
+		t.Run(fmt.Sprintf("%s fails", method), func(t *testing.T) {
+			memory := store.NewMemory()
+			e := echo.New()
+			RegisterRoutes(e, Config{Store: failingStore{memory, map[string]bool{method: true}}})
+
+			req := httptest.NewRequest(http.MethodGet, "/inbox/"+recipient.urlKey(), nil)
+			req.Header.Set("Authorization", testChallengeAuth(t, memory, recipient, time.Now(), nil))
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assertErrorResponse(t, rec, http.StatusInternalServerError, types.ErrorInternal)
+
+			var response types.ErrorResponse
+			json.Unmarshal(rec.Body.Bytes(), &response)
+			assert.True(t, response.Retryable)
+		})
+	}
+
+	t.Run("invalid key", func(t *testing.T) {
+		s := newServer(Config{})
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/inbox/!!!", nil), rec)
+		c.SetParamNames("key")
+		c.SetParamValues("!!!")
+
+		handleError(s.getInbox(c), c)
+		assertErrorResponse(t, rec, http.StatusBadRequest, types.ErrorInvalidKey)
+	})
+}
+
+func TestErrors_Routing(t *testing.T) {
+	e, _ := setupTestRouter()
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assertErrorResponse(t, rec, http.StatusNotFound, types.ErrorNotFound)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/recipients", nil))
+	assertErrorResponse(t, rec, http.StatusMethodNotAllowed, types.ErrorMethodNotAllowed)
+}
+
+func TestErrors_FromHTTPError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		code      types.ErrorCode
+		message   string
+		retryable bool
+	}{
+		{"catalogued", echo.ErrNotFound, http.StatusNotFound, types.ErrorNotFound, "Not Found", false},
+		{"catalogued with message", echo.NewHTTPError(http.StatusBadRequest, "bad limit"), http.StatusBadRequest, types.ErrorInvalidParameter, "bad limit", false},
+		{"forbidden", echo.ErrForbidden, http.StatusForbidden, "forbidden", "Forbidden", false},
+		{"unsupported media type", echo.NewHTTPError(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType, "unsupported_media_type", "Unsupported Media Type", false},
+		{"rate limited", echo.ErrTooManyRequests, http.StatusTooManyRequests, "too_many_requests", "Too Many Requests", true},
+		{"unavailable", echo.ErrServiceUnavailable, http.StatusServiceUnavailable, "service_unavailable", "Service Unavailable", true},
+		{"unknown status", echo.NewHTTPError(599), http.StatusInternalServerError, types.ErrorInternal, "internal error", true},
+		{"not an HTTP error", errors.New("boom"), http.StatusInternalServerError, types.ErrorInternal, "internal error", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := fromHTTPError(tt.err)
+			assert.Equal(t, tt.status, apiErr.status)
+			assert.Equal(t, tt.code, apiErr.code)
+			assert.Equal(t, tt.message, apiErr.message)
+			assert.Equal(t, tt.retryable, apiErr.retryable)
+		})
+	}
+
+	// Through the error handler, an unlisted status keeps its status.
+	e := echo.New()
+	RegisterRoutes(e, Config{})
+	e.GET("/forbidden", func(echo.Context) error { return echo.ErrForbidden })
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/forbidden", nil))
+	assertErrorResponse(t, rec, http.StatusForbidden, "forbidden")
+}
@@ -0,0 +1,54 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// apiVersion prefixes every route but the JWKS.
+	apiVersion = "v1"
+
+	// legacyProtocol is assumed for disclosures that do not declare a
+	// protocol.
+	legacyProtocol = 1
+
+	// maxBodyBytes bounds the body of credential and disclosure requests.
+	maxBodyBytes = 2 << 10
+)
+
+// supportedProtocols are the disclosure protocol versions accepted: the
+// formats of VerifiableShare and its commitment. A disclosure under any
+// other version is rejected rather than stored where recipients cannot
+// read it.
+var supportedProtocols = []int{1}
+
+// getInfo describes what this rendezvous point supports and enforces, so
+// clients can negotiate before disclosing.
+func (s *server) getInfo(c echo.Context) error {
+	keyIDs := []string{}
+	for _, jwk := range s.keys.JWKS().Keys {
+		keyIDs = append(keyIDs, jwk.KeyID)
+	}
+
+	return c.JSON(http.StatusOK, types.InfoResponse{
+		APIVersions: []string{apiVersion},
+		Protocols:   supportedProtocols,
+		Thresholds: types.ThresholdPolicy{
+			Default: s.thresholds.Default,
+			Orgs:    s.orgThresholds,
+			Window:  int64(s.window.Seconds()),
+		},
+		Limits: types.Limits{
+			MaxBodyBytes:       maxBodyBytes,
+			CredentialLifetime: int64(credentialLifetime.Seconds()),
+			ChallengeTTL:       int64(s.challengeTTL.Seconds()),
+			MaxChallenges:      s.maxChallenges,
+			SessionLifetime:    int64(s.sessionLifetime.Seconds()),
+			MaxLogEntries:      maxLogEntries,
+		},
+		KeyIDs: keyIDs,
+	})
+}
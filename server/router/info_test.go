@@ -0,0 +1,117 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func postTestProtocolDisclosure(e *echo.Echo, path string, credential string, recipient []byte, protocol int) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(types.DisclosureRequest{
+		ID:              "id-1",
+		Recipient:       base64.StdEncoding.EncodeToString(recipient),
+		VerifiableShare: newTestShare("share"),
+		Protocol:        protocol,
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+credential)
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestInfo(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	e := echo.New()
+	RegisterRoutes(e, Config{
+		Keys:            keys,
+		Thresholds:      Thresholds{Orgs: map[string]int{"Google LLC": 5}},
+		ThresholdWindow: time.Hour,
+		MaxChallenges:   4,
+	})
+	signTestCredential(t, keys, "OrgA")
+
+	var info types.InfoResponse
+	getTestJSON(t, e, "/v1/info", &info)
+	assert.Equal(t, []string{"v1"}, info.APIVersions)
+	assert.Equal(t, []int{1}, info.Protocols)
+	assert.Equal(t, types.ThresholdPolicy{Default: 3, Orgs: map[string]int{"Google LLC": 5}, Window: 3600}, info.Thresholds)
+	assert.Equal(t, 2048, info.Limits.MaxBodyBytes)
+	assert.Equal(t, 4, info.Limits.MaxChallenges)
+	assert.Equal(t, int64(DefaultChallengeTTL.Seconds()), info.Limits.ChallengeTTL)
+	assert.Equal(t, maxLogEntries, info.Limits.MaxLogEntries)
+
+	var jwks types.JWKS
+	getTestJSON(t, e, "/.well-known/jwks.json", &jwks)
+	assert.Len(t, jwks.Keys, 1)
+	assert.Equal(t, []string{jwks.Keys[0].KeyID}, info.KeyIDs)
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	e, s := setupTestRouter()
+	recipient := newTestRecipient()
+
+	// Registration under /v1 lists the same way as without a prefix.
+	auth := recipient.answerChallenge(t, e, "/v1/register/"+recipient.urlKey()+"/challenge")
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/register/"+recipient.urlKey(), bytes.NewReader([]byte(`{"name":"Alice"}`)))
+	req.Header.Set("Authorization", auth)
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	_, err := s.Recipient(base64.StdEncoding.EncodeToString(recipient.publicKey))
+	assert.NoError(t, err)
+
+	var recipients []types.Recipient
+	getTestJSON(t, e, "/v1/recipients", &recipients)
+	getTestJSON(t, e, "/recipients", &recipients)
+
+	// The JWKS stays at its well-known path only.
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/.well-known/jwks.json", nil))
+	assertErrorResponse(t, rec, http.StatusNotFound, types.ErrorNotFound)
+}
+
+func TestDiscloseProtocol(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Keys: keys, Thresholds: Thresholds{Default: 1}})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	// An unsupported protocol is refused before the credential is spent.
+	credential := signTestCredential(t, keys, "OrgA")
+	rec := postTestProtocolDisclosure(e, "/v1/disclose", credential, recipient.publicKey, 2)
+	assertErrorResponse(t, rec, http.StatusBadRequest, types.ErrorUnsupportedProtocol)
+
+	rec = postTestProtocolDisclosure(e, "/v1/disclose", credential, recipient.publicKey, 1)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	shares, err := s.Shares(recipient.publicKey)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 1)
+	assert.Equal(t, 1, shares[0].Protocol)
+
+	// Disclosures that do not declare a protocol are version 1.
+	other := newTestRecipient()
+	other.register(t, s)
+	rec = postTestProtocolDisclosure(e, "/disclose", signTestCredential(t, keys, "OrgA"), other.publicKey, 0)
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	inbox := other.inbox(t, e)
+	assert.Len(t, inbox, 1)
+	assert.Equal(t, 1, inbox[0].Protocol)
+}
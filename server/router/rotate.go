@@ -0,0 +1,96 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/curve25519"
+)
+
+// ShareRotation decides what happens to a recipient's pending shares when
+// it rotates to a new key.
+type ShareRotation string
+
+const (
+	// ReaddressShares moves pending shares to the new key's inbox. They stay
+	// encrypted to the old key, so the recipient must keep it to decrypt
+	// them.
+	ReaddressShares ShareRotation = "readdress"
+	// DiscardShares deletes pending shares.
+	DiscardShares ShareRotation = "discard"
+)
+
+// postRotate moves a registration from the key in the path, which answered
+// a challenge, to a new key that answered its own challenge.
+func (s *server) postRotate(c echo.Context) error {
+	var req types.RotateRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return errInvalidBody
+	}
+
+	oldKey, err := base64.RawURLEncoding.DecodeString(c.Param("key"))
+	if err != nil {
+		return errInvalidKey
+	}
+	newKey, err := base64.StdEncoding.DecodeString(req.PublicKey)
+	if err != nil || len(newKey) != curve25519.PointSize {
+		return errInvalidKey.withMessage("invalid new key")
+	}
+	if bytes.Equal(oldKey, newKey) {
+		return errInvalidKey.withMessage("new key is the current key")
+	}
+
+	// The new key proves possession with a challenge from
+	// GET /register/:key/challenge.
+	err = s.verifyChallenge(base64.RawURLEncoding.EncodeToString(newKey), req.Challenge.EncryptedToken, req.Challenge.Nonce)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.withMessage("new key: " + apiErr.message)
+	} else if err != nil {
+		return err
+	}
+
+	recipient, err := s.store.Recipient(base64.StdEncoding.EncodeToString(oldKey))
+	if errors.Is(err, store.ErrNotFound) {
+		return errNotRegistered
+	} else if err != nil {
+		return errInternal.withMessage("failed to load recipient")
+	}
+	_, err = s.store.Recipient(base64.StdEncoding.EncodeToString(newKey))
+	if err == nil {
+		return errAlreadyRegistered.withMessage("new key is already registered")
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return errInternal.withMessage("failed to load recipient")
+	}
+
+	// Register the new key before retiring the old one, so a failure part
+	// way leaves both registered and the rotation can be retried.
+	next := *recipient
+	next.PublicKey = base64.StdEncoding.EncodeToString(newKey)
+	if err := s.store.PutRecipient(next); err != nil {
+		return errInternal.withMessage("failed to store recipient")
+	}
+
+	if s.shareRotation == DiscardShares {
+		err = s.store.DeleteShares(oldKey)
+	} else {
+		err = s.store.Readdress(oldKey, newKey)
+	}
+	if err != nil {
+		return errInternal.withMessage("failed to move shares")
+	}
+
+	if err := s.store.DeleteRecipient(recipient.PublicKey); err != nil {
+		return errInternal.withMessage("failed to delete recipient")
+	}
+	if err := s.logListing(types.LogRotate, recipient, &next, recipient.PublicKey); err != nil {
+		return errInternal.withMessage("failed to log rotation")
+	}
+
+	return statusOK(c)
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rotate moves from's registration to to, answering a challenge for each.
+func rotate(t *testing.T, e *echo.Echo, from testRecipient, to testRecipient) *httptest.ResponseRecorder {
+	header := to.answerChallenge(t, e, "/register/"+to.urlKey()+"/challenge")
+	payload, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Bearer "))
+	require.NoError(t, err)
+
+	var answer types.ChallengeAuth
+	require.NoError(t, json.Unmarshal(payload, &answer))
+	body, _ := json.Marshal(types.RotateRequest{
+		PublicKey: base64.StdEncoding.EncodeToString(to.publicKey),
+		Challenge: answer,
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/register/"+from.urlKey()+"/rotate", strings.NewReader(string(body)))
+	req.Header.Set("Authorization", from.inboxAuth(t, e))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRotate_Readdress(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Keys: keys})
+
+	old, next := newTestRecipient(), newTestRecipient()
+	require.Equal(t, http.StatusOK, old.postRegister(t, e, `{"name":"Legal Team","threshold":4}`).Code)
+	credential := signTestCredential(t, keys, "OrgA")
+	require.Equal(t, http.StatusOK, postTestDisclosure(e, credential, old.publicKey, "id-1").Code)
+
+	rec := rotate(t, e, old, next)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	r, err := s.Recipient(base64.StdEncoding.EncodeToString(next.publicKey))
+	require.NoError(t, err)
+	assert.Equal(t, "Legal Team", r.Name)
+	assert.Equal(t, 4, r.Threshold)
+	_, err = s.Recipient(base64.StdEncoding.EncodeToString(old.publicKey))
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	shares, err := s.Shares(next.publicKey)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 1)
+
+	// A credential spent on the old key stays spent on the new one.
+	assert.Equal(t, http.StatusConflict, postTestDisclosure(e, credential, next.publicKey, "id-2").Code)
+}
+
+func TestRotate_Discard(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, ShareRotation: DiscardShares})
+
+	old, next := newTestRecipient(), newTestRecipient()
+	old.register(t, s)
+	s.PutShare(old.publicKey, store.Share{ID: "id-1", Org: "OrgA", SubmittedAt: time.Now()})
+
+	require.Equal(t, http.StatusOK, rotate(t, e, old, next).Code)
+
+	for _, key := range [][]byte{old.publicKey, next.publicKey} {
+		shares, err := s.Shares(key)
+		assert.NoError(t, err)
+		assert.Empty(t, shares)
+	}
+}
+
+func TestRotate_Rejected(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s})
+
+	old, next, taken := newTestRecipient(), newTestRecipient(), newTestRecipient()
+	old.register(t, s)
+	taken.register(t, s)
+
+	// The new key must answer its own challenge.
+	body, _ := json.Marshal(types.RotateRequest{
+		PublicKey: base64.StdEncoding.EncodeToString(next.publicKey),
+		Challenge: types.ChallengeAuth{Nonce: "nonce", EncryptedToken: "token"},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/register/"+old.urlKey()+"/rotate", strings.NewReader(string(body)))
+	req.Header.Set("Authorization", old.inboxAuth(t, e))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// Only the old key can sign over its registration.
+	req = httptest.NewRequest(http.MethodPost, "/register/"+old.urlKey()+"/rotate", strings.NewReader(string(body)))
+	req.Header.Set("Authorization", next.answerChallenge(t, e, "/register/"+next.urlKey()+"/challenge"))
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// A key registered to someone else cannot be taken over.
+	assert.Equal(t, http.StatusConflict, rotate(t, e, old, taken).Code)
+
+	_, err := s.Recipient(base64.StdEncoding.EncodeToString(old.publicKey))
+	assert.NoError(t, err)
+}
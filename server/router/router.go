@@ -3,103 +3,432 @@ package router
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/crypto/curve25519"
 
-	"net/http"
-
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/orgs"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/translog"
 	"github.com/berkmancenter/rendezvous-point/types"
 )
 
-func RegisterRoutes(e *echo.Echo) {
-	createKeys()
+// Config holds the dependencies of a rendezvous point.
+type Config struct {
+	// Store persists recipients, challenges and shares. Defaults to an
+	// in-memory store.
+	Store store.Store
+	// Keys signs and verifies credentials. Defaults to an in-memory keyring
+	// that rotates weekly.
+	Keys *keyring.Keyring
+	// LogKey signs directories and transparency log tree heads, which are
+	// kept as evidence long after credential keys retire, so it should not
+	// rotate. Defaults to an in-memory key.
+	LogKey *keyring.Keyring
+	// Resolver maps requesting IP addresses to organizations. Defaults to
+	// live RDAP queries.
+	Resolver orgs.Resolver
+	// Aliases canonicalizes organization names when issuing credentials
+	// and when grouping shares toward a threshold.
+	Aliases *orgs.Aliases
+	// Thresholds sets how many shares from one organization are needed
+	// before release. Defaults to 3 for every organization.
+	Thresholds Thresholds
+	// ThresholdWindow is how recent shares must be to count toward a
+	// threshold, so old disclosures are not grouped with new ones. Zero
+	// counts every stored share.
+	ThresholdWindow time.Duration
+	// ChallengeTTL is how long an inbox challenge can be answered. Defaults
+	// to DefaultChallengeTTL.
+	ChallengeTTL time.Duration
+	// MaxChallenges caps the unanswered challenges per recipient. Defaults
+	// to 5.
+	MaxChallenges int
+	// MaxRegisterChallenges caps the unanswered registration challenges per
+	// key. Defaults to 5.
+	MaxRegisterChallenges int
+	// SessionLifetime is how long an inbox session lasts. Defaults to
+	// DefaultSessionLifetime.
+	SessionLifetime time.Duration
+	// ShareRotation decides what happens to pending shares when a
+	// recipient rotates keys. Defaults to ReaddressShares.
+	ShareRotation ShareRotation
+	// TokenKeyBits is the size of the RSA keys that blind sign tokens.
+	// Defaults to 2048.
+	TokenKeyBits int
+	// MaxTokenKeys caps how many organizations are issued a token key per
+	// epoch. Defaults to 1000.
+	MaxTokenKeys int
+	// AdminToken authenticates the operator to the /admin API. Empty leaves
+	// the API unregistered.
+	AdminToken string
+}
+
+type server struct {
+	store      store.Store
+	keys       *keyring.Keyring
+	logKey     *keyring.Keyring
+	resolver   orgs.Resolver
+	aliases    *orgs.Aliases
+	thresholds Thresholds
+	// orgThresholds are the overrides as configured, before normalization,
+	// for publishing in GET /info.
+	orgThresholds map[string]int
+	tokens        *tokenKeys
+	window        time.Duration
+	// log caches the transparency log's hashes. logMu serializes bringing
+	// it up to date with the store.
+	log   *translog.Log
+	logMu sync.Mutex
+
+	challengeTTL          time.Duration
+	maxChallenges         int
+	maxRegisterChallenges int
+	sessionLifetime       time.Duration
+	shareRotation         ShareRotation
+	adminToken            string
+}
+
+func newServer(cfg Config) *server {
+	if cfg.Store == nil {
+		cfg.Store = store.NewMemory()
+	}
+	if cfg.Keys == nil {
+		keys, err := keyring.New(DefaultKeyringOptions())
+		if err != nil {
+			// Only a keyring persisted to disk can fail to open, so this
+			// is a bug rather than a configuration error.
+			panic(fmt.Sprintf("router: creating default keyring: %v", err))
+		}
+		cfg.Keys = keys
+	}
+	if cfg.LogKey == nil {
+		// Without RotateEvery the one key never rotates, and with nothing
+		// in Dir nothing can fail.
+		cfg.LogKey, _ = keyring.New(keyring.Options{})
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = orgs.NewRDAP()
+	}
+	if cfg.ChallengeTTL == 0 {
+		cfg.ChallengeTTL = DefaultChallengeTTL
+	}
+	if cfg.MaxChallenges == 0 {
+		cfg.MaxChallenges = defaultMaxChallenges
+	}
+	if cfg.MaxRegisterChallenges == 0 {
+		cfg.MaxRegisterChallenges = defaultMaxRegisterChallenges
+	}
+	if cfg.SessionLifetime == 0 {
+		cfg.SessionLifetime = DefaultSessionLifetime
+	}
+	return &server{
+		store:         cfg.Store,
+		keys:          cfg.Keys,
+		logKey:        cfg.LogKey,
+		resolver:      cfg.Resolver,
+		aliases:       cfg.Aliases,
+		thresholds:    cfg.Thresholds.normalized(),
+		orgThresholds: cfg.Thresholds.Orgs,
+		tokens:        newTokenKeys(cfg.Store, cfg.TokenKeyBits, cfg.MaxTokenKeys),
+		window:        cfg.ThresholdWindow,
+		log:           &translog.Log{},
+
+		challengeTTL:          cfg.ChallengeTTL,
+		maxChallenges:         cfg.MaxChallenges,
+		maxRegisterChallenges: cfg.MaxRegisterChallenges,
+		sessionLifetime:       cfg.SessionLifetime,
+		shareRotation:         cfg.ShareRotation,
+		adminToken:            cfg.AdminToken,
+	}
+}
+
+// DefaultKeyringOptions rotates signing keys weekly and keeps retired keys
+// verifying until every credential they signed has expired.
+func DefaultKeyringOptions() keyring.Options {
+	return keyring.Options{
+		RotateEvery: 7 * 24 * time.Hour,
+		VerifyFor:   credentialLifetime,
+	}
+}
+
+func RegisterRoutes(e *echo.Echo, cfg Config) {
+	newServer(cfg).register(e)
+}
+
+func (s *server) register(e *echo.Echo) {
+	e.HTTPErrorHandler = handleError
 
-	e.GET("/credential", getCredential)
-	e.POST("/disclose", postDisclose, middleware.BodyLimit("2K"), echojwt.WithConfig(echojwt.Config{
-		SigningKey:    &signingKey.PublicKey,
-		SigningMethod: "ES256",
-	}))
-	e.POST("/register", postRegister)
-	e.GET("/recipients", getRecipients)
-	e.GET("/inbox/:key/challenge", getInboxChallenge)
-	e.GET("/inbox/:key", getInbox, challengeAuth)
-	e.DELETE("/inbox/:key/:id", deleteInboxId, challengeAuth)
+	e.GET("/.well-known/jwks.json", s.getJWKS)
+	e.GET("/.well-known/log-jwks.json", s.getLogJWKS)
+	s.routes(e.Group("/" + apiVersion))
+	// Unversioned paths predate /v1 and are kept for the clients using them.
+	s.routes(e.Group(""))
 }
 
-func getCredential(c echo.Context) error {
-	credential, err := newCredential(c)
+func (s *server) routes(g *echo.Group) {
+	bodyLimit := middleware.BodyLimit(strconv.Itoa(maxBodyBytes))
+
+	g.GET("/info", s.getInfo)
+	g.GET("/credential", s.getCredential)
+	g.GET("/credential/token-key", s.getTokenKey)
+	g.POST("/credential", s.postCredential, bodyLimit)
+	g.POST("/disclose", s.postDisclose, bodyLimit, s.credentialAuth)
+	g.GET("/register/:key/challenge", s.getRegisterChallenge)
+	g.POST("/register/:key", s.postRegister, s.challengeAuth)
+	g.DELETE("/register/:key", s.deleteRegister, s.challengeAuth)
+	g.POST("/register/:key/rotate", s.postRotate, s.challengeAuth)
+	g.GET("/recipients", s.getRecipients)
+	g.GET("/directory", s.getDirectory)
+	g.GET("/log/head", s.getLogHead)
+	g.GET("/log/entries", s.getLogEntries)
+	g.GET("/log/proof/inclusion", s.getInclusionProof)
+	g.GET("/log/proof/consistency", s.getConsistencyProof)
+	g.GET("/inbox/:key/challenge", s.getInboxChallenge)
+	g.POST("/inbox/:key/session", s.postInboxSession, s.challengeAuth)
+	g.GET("/inbox/:key", s.getInbox, s.sessionAuth)
+	g.DELETE("/inbox/:key/:id", s.deleteInboxId, s.sessionAuth)
+
+	if s.adminToken != "" {
+		admin := g.Group("/admin", s.adminAuth)
+		admin.GET("/recipients", s.getAdminRecipients)
+		admin.PUT("/recipients/:key", s.putAdminRecipient)
+	}
+}
+
+func (s *server) getJWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.keys.JWKS())
+}
+
+func (s *server) getLogJWKS(c echo.Context) error {
+	return c.JSON(http.StatusOK, s.logKey.JWKS())
+}
+
+func (s *server) getCredential(c echo.Context) error {
+	credential, err := s.newCredential(c)
 	if err != nil {
 		return err
 	}
 	return c.JSON(http.StatusOK, credential)
 }
 
-func postDisclose(c echo.Context) error {
+func (s *server) postDisclose(c echo.Context) error {
 	var req types.DisclosureRequest
 	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
-		return c.String(http.StatusBadRequest, "invalid body")
+		return errInvalidBody
 	}
 
 	key, err := base64.StdEncoding.DecodeString(req.Recipient)
 	if err != nil {
-		return c.String(http.StatusBadRequest, "invalid key encoding")
+		return errInvalidKey
 	}
 
-	user := c.Get("user").(*jwt.Token)
-	claims := user.Claims.(jwt.MapClaims)
-	org := claims["org"].(string)
+	protocol := req.Protocol
+	if protocol == 0 {
+		protocol = legacyProtocol
+	}
+	if !slices.Contains(supportedProtocols, protocol) {
+		return errUnsupportedProtocol.withMessage(fmt.Sprintf("unsupported protocol %d", protocol))
+	}
+	// Malformed shares would otherwise only fail on the recipient's device,
+	// once enough have arrived to combine.
+	if err := validateShare(req.VerifiableShare); err != nil {
+		return err
+	}
 
-	disclosuresMu.Lock()
-	defer disclosuresMu.Unlock()
-	if disclosures[string(key)] == nil {
-		disclosures[string(key)] = make(map[string]map[string]types.VerifiableShare)
+	// A credential counts once, so a single whistleblower cannot meet a
+	// threshold alone.
+	cred := c.Get("credential").(*credential)
+	scope := cred.scope
+	if scope == nil {
+		scope = key
 	}
-	if disclosures[string(key)][org] == nil {
-		disclosures[string(key)][org] = make(map[string]types.VerifiableShare)
+	if err := s.store.SpendCredential(scope, cred.id, cred.expires); err != nil {
+		if errors.Is(err, store.ErrSpent) {
+			return errCredentialUsed
+		}
+		return errInternal.withMessage("failed to record credential")
 	}
-	disclosures[string(key)][org][req.ID] = req.VerifiableShare
-	return c.String(http.StatusOK, "transmission successful")
+
+	err = s.store.PutShare(key, store.Share{
+		ID:              req.ID,
+		Org:             cred.org,
+		VerifiableShare: req.VerifiableShare,
+		SubmittedAt:     time.Now(),
+		Protocol:        protocol,
+	})
+	if err != nil {
+		return errInternal.withMessage("failed to store share")
+	}
+
+	return statusOK(c)
 }
 
-func postRegister(c echo.Context) error {
+// postRegister registers or updates the recipient whose key answered a
+// challenge, so nobody can register or rename a key they do not hold.
+func (s *server) postRegister(c echo.Context) error {
 	var r types.Recipient
-	if err := c.Bind(&r); err != nil {
-		return c.String(http.StatusBadRequest, "invalid body")
+	if err := json.NewDecoder(c.Request().Body).Decode(&r); err != nil {
+		return errInvalidBody
 	}
-	recipientsMu.Lock()
-	defer recipientsMu.Unlock()
-	recipients[r.PublicKey] = r.Name
-	return c.String(http.StatusOK, "ok")
+
+	publicKey, err := base64.RawURLEncoding.DecodeString(c.Param("key"))
+	if err != nil {
+		return errInvalidKey
+	}
+	encodedKey := base64.StdEncoding.EncodeToString(publicKey)
+	if r.PublicKey != "" && r.PublicKey != encodedKey {
+		return errKeyMismatch
+	}
+	r.PublicKey = encodedKey
+
+	if r.Threshold < 0 {
+		return errInvalidParameter.withMessage("invalid threshold")
+	}
+
+	// Review is the operator's to change. A new key waits for approval, and
+	// so does an approved recipient who renames itself, since the name is
+	// what whistleblowers pick by.
+	action := types.LogUpdate
+	existing, err := s.store.Recipient(encodedKey)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		action = types.LogRegister
+		r.Status = types.RecipientPending
+		r.Organization, r.Contact, r.Notes = "", "", ""
+	case err != nil:
+		return errInternal.withMessage("failed to load recipient")
+	default:
+		r.Status = existing.Status
+		r.Organization, r.Contact, r.Notes = existing.Organization, existing.Contact, existing.Notes
+		if r.Name != existing.Name && r.Status == types.RecipientApproved {
+			r.Status = types.RecipientPending
+		}
+	}
+
+	if err := s.store.PutRecipient(r); err != nil {
+		return errInternal.withMessage("failed to store recipient")
+	}
+	if err := s.logListing(action, existing, &r, ""); err != nil {
+		return errInternal.withMessage("failed to log registration")
+	}
+
+	return statusOK(c)
+}
+
+// deleteRegister retires the recipient whose key answered a challenge,
+// discarding its pending shares.
+func (s *server) deleteRegister(c echo.Context) error {
+	publicKey, err := base64.RawURLEncoding.DecodeString(c.Param("key"))
+	if err != nil {
+		return errInvalidKey
+	}
+
+	recipient, err := s.store.Recipient(base64.StdEncoding.EncodeToString(publicKey))
+	if errors.Is(err, store.ErrNotFound) {
+		return errNotRegistered
+	} else if err != nil {
+		return errInternal.withMessage("failed to load recipient")
+	}
+
+	if err := s.store.DeleteRecipient(recipient.PublicKey); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return errInternal.withMessage("failed to delete recipient")
+	}
+	if err := s.logListing(types.LogDeregister, recipient, nil, ""); err != nil {
+		return errInternal.withMessage("failed to log deregistration")
+	}
+
+	if err := s.store.DeleteShares(publicKey); err != nil {
+		return errInternal.withMessage("failed to delete shares")
+	}
+
+	return statusOK(c)
 }
 
-func getRecipients(c echo.Context) error {
-	recipientsMu.RLock()
-	defer recipientsMu.RUnlock()
-	var result []types.Recipient
-	for key, name := range recipients {
-		result = append(result, types.Recipient{Name: name, PublicKey: key})
+// getRecipients lists the directory whistleblowers pick from: approved
+// recipients only, without the operator's notes.
+func (s *server) getRecipients(c echo.Context) error {
+	result, err := s.approvedRecipients()
+	if err != nil {
+		return errInternal.withMessage("failed to load recipients")
 	}
 	return c.JSON(http.StatusOK, result)
 }
 
-func getInboxChallenge(c echo.Context) error {
+func (s *server) approvedRecipients() ([]types.Recipient, error) {
+	recipients, err := s.store.Recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []types.Recipient{}
+	for _, r := range recipients {
+		if r.Status != types.RecipientApproved {
+			continue
+		}
+		r.Notes = ""
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (s *server) getInboxChallenge(c echo.Context) error {
+	publicKey, err := base64.RawURLEncoding.DecodeString(c.Param("key"))
+	if err != nil {
+		return errInvalidKey
+	}
+
+	// Only registered recipients have an inbox worth authenticating to, and
+	// refusing others keeps random keys from filling the store.
+	_, err = s.store.Recipient(base64.StdEncoding.EncodeToString(publicKey))
+	if errors.Is(err, store.ErrNotFound) {
+		return errNotRegistered
+	} else if err != nil {
+		return errInternal.withMessage("failed to load recipient")
+	}
+
+	return s.issueChallenge(c, c.Param("key"), s.maxChallenges)
+}
+
+// getRegisterChallenge lets a key that is not yet registered prove
+// possession. Each key has its own few slots, apart from its inbox
+// challenges, so that asking for challenges can neither crowd out another
+// key's registration or rotation nor a recipient's inbox.
+func (s *server) getRegisterChallenge(c echo.Context) error {
+	return s.issueChallenge(c, registerChallengePrefix+c.Param("key"), s.maxRegisterChallenges)
+}
+
+// issueChallenge issues a challenge for the key in the path, storing it in
+// pool.
+func (s *server) issueChallenge(c echo.Context, pool string, limit int) error {
 	key := c.Param("key")
+	publicKey, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil || len(publicKey) != curve25519.PointSize || base64.RawURLEncoding.EncodeToString(publicKey) != key {
+		return errInvalidKey
+	}
+
 	challenge, err := newChallenge()
 	if err != nil {
-		return c.String(http.StatusInternalServerError, "failed to generate challenge")
+		return errInternal.withMessage("failed to generate challenge")
 	}
 
 	encodedNonce := base64.StdEncoding.EncodeToString(challenge.Nonce)
 
-	challengesMu.Lock()
-	defer challengesMu.Unlock()
-	if challenges[key] == nil {
-		challenges[key] = make(map[string]types.Challenge)
+	err = s.store.PutChallenge(pool, encodedNonce, *challenge, limit, time.Now().Add(-s.challengeTTL))
+	if errors.Is(err, store.ErrLimit) {
+		return errTooManyChallenges
+	} else if err != nil {
+		return errInternal.withMessage("failed to store challenge")
 	}
-	challenges[key][encodedNonce] = *challenge
 
 	return c.JSON(http.StatusOK, types.InboxChallengeResponse{
 		Token:     base64.StdEncoding.EncodeToString(challenge.Token),
@@ -108,56 +437,70 @@ func getInboxChallenge(c echo.Context) error {
 	})
 }
 
-func getInbox(c echo.Context) error {
+func (s *server) getInbox(c echo.Context) error {
 	urlEncodedKey := c.Param("key")
 	key, err := base64.RawURLEncoding.DecodeString(urlEncodedKey)
 	if err != nil {
-		return c.String(http.StatusBadRequest, "invalid key encoding")
+		return errInvalidKey
 	}
 
-	disclosuresMu.RLock()
-	defer disclosuresMu.RUnlock()
+	shares, err := s.store.Shares(key)
+	if err != nil {
+		return errInternal.withMessage("failed to load shares")
+	}
+
+	// Shares may be addressed to keys that never registered, which only
+	// get the operator's policy.
+	recipient, err := s.store.Recipient(base64.StdEncoding.EncodeToString(key))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return errInternal.withMessage("failed to load recipient")
+	}
+
+	// Group under canonical names, so credentials issued before an alias
+	// was added still count toward the same organization.
+	byOrg := map[string][]store.Share{}
+	names := map[string]string{}
+	for _, share := range shares {
+		if s.window > 0 && time.Since(share.SubmittedAt) > s.window {
+			continue
+		}
+		name := s.aliases.CanonicalName(share.Org)
+		key := orgs.Normalize(name)
+		byOrg[key] = append(byOrg[key], share)
+		if existing, ok := names[key]; !ok || name < existing {
+			names[key] = name
+		}
+	}
 
-	entries := disclosures[string(key)]
 	var result []types.InboxResponse
-	for org, values := range entries {
-		if len(values) >= threshold {
-			for id, share := range values {
+	for key, values := range byOrg {
+		if len(values) >= s.thresholds.For(key, recipient) {
+			for _, share := range values {
 				result = append(result, types.InboxResponse{
-					ID:              id,
-					Org:             org,
-					VerifiableShare: share,
+					ID:              share.ID,
+					Org:             names[key],
+					VerifiableShare: share.VerifiableShare,
+					Protocol:        max(share.Protocol, legacyProtocol),
 				})
 			}
 		}
 	}
+
 	return c.JSON(http.StatusOK, result)
 }
 
-func deleteInboxId(c echo.Context) error {
+func (s *server) deleteInboxId(c echo.Context) error {
 	urlEncodedKey := c.Param("key")
 	key, err := base64.RawURLEncoding.DecodeString(urlEncodedKey)
 	if err != nil {
-		return c.String(http.StatusBadRequest, "invalid key encoding")
+		return errInvalidKey
 	}
-	id := c.Param("id")
 
-	disclosuresMu.Lock()
-	defer disclosuresMu.Unlock()
+	id := c.Param("id")
 
-	if orgs, ok := disclosures[string(key)]; ok {
-		for org, idMap := range orgs {
-			if _, exists := idMap[id]; exists {
-				delete(idMap, id)
-				if len(idMap) == 0 {
-					delete(orgs, org)
-				}
-			}
-		}
-		if len(orgs) == 0 {
-			delete(disclosures, string(key))
-		}
+	if err := s.store.DeleteShare(key, id); err != nil {
+		return errInternal.withMessage("failed to delete share")
 	}
 
-	return c.String(http.StatusOK, "ok")
+	return statusOK(c)
 }
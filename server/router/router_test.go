@@ -3,48 +3,233 @@ package router
 import (
 	"bytes"
 	cryptoRand "crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/orgs"
+	"github.com/berkmancenter/rendezvous-point/store"
 	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/curve25519"
 )
 
-func setupTestRouter() *echo.Echo {
-	createKeys()
+// testResolver stands in for RDAP, mapping 8.8.8.0/24 to Google.
+var testResolver = orgs.ResolverFunc(func(addr netip.Addr) (*orgs.Organization, error) {
+	prefix := netip.MustParsePrefix("8.8.8.0/24")
+	if !prefix.Contains(addr) {
+		return nil, orgs.ErrNotFound
+	}
+	return &orgs.Organization{Name: "Google LLC", Prefix: prefix}, nil
+})
+
+func setupTestRouter() (*echo.Echo, store.Store) {
+	s := store.NewMemory()
 	e := echo.New()
-	RegisterRoutes(e)
-	return e
+	RegisterRoutes(e, Config{Store: s, Resolver: testResolver})
+	return e, s
 }
 
-func TestRegisterAndListRecipients(t *testing.T) {
-	e := setupTestRouter()
+func signTestCredential(t *testing.T, keys *keyring.Keyring, org string) string {
+	jti, err := newCredentialID()
+	assert.NoError(t, err)
+	signed, err := keys.Sign(jwt.MapClaims{
+		"jti": jti,
+		"org": org,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	})
+	assert.NoError(t, err)
+	return signed
+}
+
+func postTestDisclosure(e *echo.Echo, credential string, recipient []byte, id string) *httptest.ResponseRecorder {
+	return postTestDisclosureAuth(e, "Bearer "+credential, recipient, id)
+}
+
+func postTestDisclosureAuth(e *echo.Echo, authorization string, recipient []byte, id string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(types.DisclosureRequest{
+		ID:              id,
+		Recipient:       base64.StdEncoding.EncodeToString(recipient),
+		VerifiableShare: newTestShare(id),
+	})
+
 	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/disclose", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// newTestShare returns a well formed share whose data is derived from seed.
+func newTestShare(seed string) types.VerifiableShare {
+	data := sha256.Sum256([]byte(seed))
+	commitment := sha256.Sum256(data[:])
+	return types.VerifiableShare{
+		Data:         base64.StdEncoding.EncodeToString(append([]byte{1}, data[:]...)),
+		EphemeralKey: base64.StdEncoding.EncodeToString(newTestRecipient().publicKey),
+		Commitment:   base64.StdEncoding.EncodeToString(commitment[:]),
+	}
+}
+
+// testRecipient is an X25519 key pair for exercising inbox routes.
+type testRecipient struct {
+	privateKey []byte
+	publicKey  []byte
+}
 
-	body := `{"name":"Alice","publicKey":"testkey"}`
-	req := httptest.NewRequest(http.MethodPost, "/register", bytes.NewBufferString(body))
+func newTestRecipient() testRecipient {
+	privateKey := make([]byte, 32)
+	cryptoRand.Read(privateKey)
+	publicKey, _ := curve25519.X25519(privateKey, curve25519.Basepoint)
+	return testRecipient{privateKey: privateKey, publicKey: publicKey}
+}
+
+func (r testRecipient) urlKey() string {
+	return base64.RawURLEncoding.EncodeToString(r.publicKey)
+}
+
+func (r testRecipient) register(t *testing.T, s store.Store) {
+	err := s.PutRecipient(types.Recipient{Name: "Recipient", PublicKey: base64.StdEncoding.EncodeToString(r.publicKey)})
+	assert.NoError(t, err)
+}
+
+// inboxAuth answers a fresh inbox challenge, returning the Authorization
+// header value.
+func (r testRecipient) inboxAuth(t *testing.T, e *echo.Echo) string {
+	return r.answerChallenge(t, e, "/inbox/"+r.urlKey()+"/challenge")
+}
+
+// postRegister registers the recipient with body, proving possession of
+// its key.
+func (r testRecipient) postRegister(t *testing.T, e *echo.Echo, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/register/"+r.urlKey(), strings.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", r.answerChallenge(t, e, "/register/"+r.urlKey()+"/challenge"))
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func (r testRecipient) answerChallenge(t *testing.T, e *echo.Echo, path string) string {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp types.InboxChallengeResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	token, _ := base64.StdEncoding.DecodeString(resp.Token)
+	serverPublicKey, _ := base64.StdEncoding.DecodeString(resp.PublicKey)
+
+	encryptedToken, err := encryptedToken(token, r.privateKey, serverPublicKey)
+	assert.NoError(t, err)
+
+	jsonPayload := fmt.Sprintf(`{"nonce":"%s","encryptedToken":"%s"}`, resp.Nonce, *encryptedToken)
+	return "Bearer " + base64.StdEncoding.EncodeToString([]byte(jsonPayload))
+}
+
+func (r testRecipient) inbox(t *testing.T, e *echo.Echo) []types.InboxResponse {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/inbox/"+r.urlKey(), nil)
+	req.Header.Set("Authorization", r.inboxAuth(t, e))
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
+	var result []types.InboxResponse
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	return result
+}
+
+func TestRegisterAndListRecipients(t *testing.T) {
+	e, s := setupTestRouter()
+	recipient := newTestRecipient()
+	encodedKey := base64.StdEncoding.EncodeToString(recipient.publicKey)
+
+	// Review fields in the body are ignored.
+	body := fmt.Sprintf(`{"name":"Alice","publicKey":"%s","status":"approved","notes":"trust me"}`, encodedKey)
+	rec := recipient.postRegister(t, e, body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	r, err := s.Recipient(encodedKey)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.Recipient{Name: "Alice", PublicKey: encodedKey, Status: types.RecipientPending}, r)
+
+	// Pending recipients are not listed.
 	rec = httptest.NewRecorder()
-	req = httptest.NewRequest(http.MethodGet, "/recipients", nil)
+	req := httptest.NewRequest(http.MethodGet, "/recipients", nil)
 	e.ServeHTTP(rec, req)
 	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.Contains(t, rec.Body.String(), "["+body+"]")
+	assert.JSONEq(t, `[]`, rec.Body.String())
+}
+
+func TestRegisterRequiresProofOfPossession(t *testing.T) {
+	e, s := setupTestRouter()
+	victim := newTestRecipient()
+	attacker := newTestRecipient()
+	victim.postRegister(t, e, `{"name":"Legal Team"}`)
+
+	register := func(authorization string, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/register/"+victim.urlKey(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Without an answered challenge, or with one answered by another key,
+	// nobody can rename the victim's key.
+	assert.Equal(t, http.StatusUnauthorized, register("", `{"name":"Bogus"}`))
+	assert.Equal(t, http.StatusUnauthorized, register(attacker.answerChallenge(t, e, "/register/"+attacker.urlKey()+"/challenge"), `{"name":"Bogus"}`))
+
+	// A challenge for the victim's key answered with the attacker's key
+	// fails too.
+	challenge := httptest.NewRecorder()
+	e.ServeHTTP(challenge, httptest.NewRequest(http.MethodGet, "/register/"+victim.urlKey()+"/challenge", nil))
+	var resp types.InboxChallengeResponse
+	json.Unmarshal(challenge.Body.Bytes(), &resp)
+	token, _ := base64.StdEncoding.DecodeString(resp.Token)
+	serverPublicKey, _ := base64.StdEncoding.DecodeString(resp.PublicKey)
+	forged, err := encryptedToken(token, attacker.privateKey, serverPublicKey)
+	assert.NoError(t, err)
+	payload := fmt.Sprintf(`{"nonce":"%s","encryptedToken":"%s"}`, resp.Nonce, *forged)
+	assert.Equal(t, http.StatusUnauthorized, register("Bearer "+base64.StdEncoding.EncodeToString([]byte(payload)), `{"name":"Bogus"}`))
+
+	// A body naming another key is rejected.
+	rec := victim.postRegister(t, e, fmt.Sprintf(`{"name":"Bogus","publicKey":"%s"}`, base64.StdEncoding.EncodeToString(attacker.publicKey)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	r, err := s.Recipient(base64.StdEncoding.EncodeToString(victim.publicKey))
+	assert.NoError(t, err)
+	assert.Equal(t, "Legal Team", r.Name)
+
+	// The key holder can rename it.
+	assert.Equal(t, http.StatusOK, victim.postRegister(t, e, `{"name":"Legal"}`).Code)
+	r, err = s.Recipient(base64.StdEncoding.EncodeToString(victim.publicKey))
+	assert.NoError(t, err)
+	assert.Equal(t, "Legal", r.Name)
+
+	_, err = s.Recipient(base64.StdEncoding.EncodeToString(attacker.publicKey))
+	assert.ErrorIs(t, err, store.ErrNotFound)
 }
 
 func TestCredentialIssue(t *testing.T) {
-	e := setupTestRouter()
+	e, _ := setupTestRouter()
 
 	req := httptest.NewRequest(http.MethodGet, "/credential", nil)
-	req.RemoteAddr = "8.8.8.8:1234" // triggers RDAP call
+	req.RemoteAddr = "8.8.8.8:1234"
 	rec := httptest.NewRecorder()
 	e.ServeHTTP(rec, req)
 
@@ -57,8 +242,278 @@ func TestCredentialIssue(t *testing.T) {
 	assert.NotEmpty(t, resp["credential"])
 }
 
+func TestCredentialIssue_UnknownOrganization(t *testing.T) {
+	e, _ := setupTestRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/credential", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
+
+func TestCredentialIssue_CanonicalOrganization(t *testing.T) {
+	aliases, err := orgs.ParseAliases(strings.NewReader(`{"Alphabet": ["Google LLC"]}`))
+	assert.NoError(t, err)
+	e := echo.New()
+	RegisterRoutes(e, Config{Resolver: testResolver, Aliases: aliases})
+
+	req := httptest.NewRequest(http.MethodGet, "/credential", nil)
+	req.RemoteAddr = "8.8.8.8:1234"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	assert.Equal(t, "Alphabet", resp["organization"])
+}
+
+func TestInboxGroupsOrganizationAliases(t *testing.T) {
+	aliases, err := orgs.ParseAliases(strings.NewReader(`{"Harvard University": ["President and Fellows of Harvard College"]}`))
+	assert.NoError(t, err)
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Aliases: aliases})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	// Three spellings of one employer reach the threshold together.
+	for i, org := range []string{"Harvard University", "HARVARD UNIVERSITY", "President and Fellows of Harvard College"} {
+		s.PutShare(recipient.publicKey, store.Share{ID: fmt.Sprintf("harvard-%d", i), Org: org})
+	}
+	// Two spellings of another employer stay below the threshold.
+	for i, org := range []string{"Example, Inc.", "EXAMPLE INC"} {
+		s.PutShare(recipient.publicKey, store.Share{ID: fmt.Sprintf("example-%d", i), Org: org})
+	}
+
+	result := recipient.inbox(t, e)
+	assert.Len(t, result, 3)
+	for _, entry := range result {
+		assert.Equal(t, "Harvard University", entry.Org)
+	}
+}
+
+func TestInboxThresholds(t *testing.T) {
+	putShares := func(s store.Store, recipient []byte, org string, n int) {
+		for i := 0; i < n; i++ {
+			s.PutShare(recipient, store.Share{ID: fmt.Sprintf("%s-%d", org, i), Org: org})
+		}
+	}
+	inboxOrgs := func(t *testing.T, e *echo.Echo, recipient testRecipient) map[string]int {
+		counts := map[string]int{}
+		for _, entry := range recipient.inbox(t, e) {
+			counts[entry.Org]++
+		}
+		return counts
+	}
+
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Thresholds: Thresholds{
+		Default: 3,
+		Orgs:    map[string]int{"Big Corp": 5, "Small Co": 2},
+	}})
+
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+	putShares(s, recipient.publicKey, "Big Corp", 4)
+	putShares(s, recipient.publicKey, "Small Co", 2)
+	putShares(s, recipient.publicKey, "Other Org", 3)
+	assert.Equal(t, map[string]int{"Small Co": 2, "Other Org": 3}, inboxOrgs(t, e, recipient))
+
+	// A recipient's own minimum raises, but never lowers, the policy.
+	strict := newTestRecipient()
+	rec := strict.postRegister(t, e, `{"name":"Strict","threshold":4}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	putShares(s, strict.publicKey, "Big Corp", 5)
+	putShares(s, strict.publicKey, "Small Co", 3)
+	putShares(s, strict.publicKey, "Other Org", 4)
+	assert.Equal(t, map[string]int{"Big Corp": 5, "Other Org": 4}, inboxOrgs(t, e, strict))
+}
+
+func TestInboxThresholdWindow(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, ThresholdWindow: 24 * time.Hour})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+	now := time.Now()
+
+	// A share from long ago does not help today's shares meet the threshold.
+	s.PutShare(recipient.publicKey, store.Share{ID: "a-old", Org: "OrgA", SubmittedAt: now.Add(-48 * time.Hour)})
+	s.PutShare(recipient.publicKey, store.Share{ID: "a-1", Org: "OrgA", SubmittedAt: now.Add(-time.Hour)})
+	s.PutShare(recipient.publicKey, store.Share{ID: "a-2", Org: "OrgA", SubmittedAt: now})
+	for i := 0; i < 3; i++ {
+		s.PutShare(recipient.publicKey, store.Share{ID: fmt.Sprintf("b-%d", i), Org: "OrgB", SubmittedAt: now.Add(-time.Duration(i) * time.Hour)})
+	}
+
+	result := recipient.inbox(t, e)
+	assert.Len(t, result, 3)
+	for _, entry := range result {
+		assert.Equal(t, "OrgB", entry.Org)
+	}
+}
+
+func TestDiscloseRecordsSubmissionTime(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Keys: keys})
+
+	rec := postTestDisclosure(e, signTestCredential(t, keys, "OrgA"), []byte("recipient"), "id-1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	shares, err := s.Shares([]byte("recipient"))
+	assert.NoError(t, err)
+	assert.Len(t, shares, 1)
+	assert.WithinDuration(t, time.Now(), shares[0].SubmittedAt, time.Minute)
+}
+
+func TestDeregister(t *testing.T) {
+	e, s := setupTestRouter()
+	recipient, other := newTestRecipient(), newTestRecipient()
+	recipient.register(t, s)
+	other.register(t, s)
+	s.PutShare(recipient.publicKey, store.Share{ID: "id-1", Org: "OrgA", SubmittedAt: time.Now()})
+
+	deregister := func(r testRecipient, authorization string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/register/"+r.urlKey(), nil)
+		req.Header.Set("Authorization", authorization)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, deregister(recipient, other.inboxAuth(t, e)))
+	assert.Equal(t, http.StatusOK, deregister(recipient, recipient.inboxAuth(t, e)))
+
+	recipients, err := s.Recipients()
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Recipient{{Name: "Recipient", PublicKey: base64.StdEncoding.EncodeToString(other.publicKey)}}, recipients)
+	shares, err := s.Shares(recipient.publicKey)
+	assert.NoError(t, err)
+	assert.Empty(t, shares)
+
+	// A deregistered key can no longer open its inbox.
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/inbox/"+recipient.urlKey()+"/challenge", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRegisterRejectsNegativeThreshold(t *testing.T) {
+	e, _ := setupTestRouter()
+
+	rec := newTestRecipient().postRegister(t, e, `{"name":"Alice","threshold":-1}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestDiscloseAcrossKeyRotation(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{VerifyFor: time.Hour})
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Keys: keys})
+	recipient := []byte("recipient")
+
+	rec := postTestDisclosure(e, signTestCredential(t, keys, "OrgA"), recipient, "id-1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	credential := signTestCredential(t, keys, "OrgA")
+
+	// Credentials signed before a rotation keep verifying.
+	_, err := keys.Rotate()
+	assert.NoError(t, err)
+	rec = postTestDisclosure(e, credential, recipient, "id-2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	shares, err := s.Shares(recipient)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 2)
+}
+
+func TestDiscloseRejectsReusedCredential(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Keys: keys})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	// One whistleblower posting three disclosure IDs under one credential
+	// must not meet the threshold for their organization.
+	credential := signTestCredential(t, keys, "OrgA")
+	assert.Equal(t, http.StatusOK, postTestDisclosure(e, credential, recipient.publicKey, "id-1").Code)
+	assert.Equal(t, http.StatusConflict, postTestDisclosure(e, credential, recipient.publicKey, "id-2").Code)
+	assert.Equal(t, http.StatusConflict, postTestDisclosure(e, credential, recipient.publicKey, "id-3").Code)
+
+	shares, err := s.Shares(recipient.publicKey)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 1)
+	assert.Empty(t, recipient.inbox(t, e))
+
+	// The same credential may still reach a different recipient.
+	other := newTestRecipient()
+	assert.Equal(t, http.StatusOK, postTestDisclosure(e, credential, other.publicKey, "id-1").Code)
+
+	// Three separate credentials meet the threshold.
+	for _, id := range []string{"id-2", "id-3"} {
+		rec := postTestDisclosure(e, signTestCredential(t, keys, "OrgA"), recipient.publicKey, id)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Len(t, recipient.inbox(t, e), 3)
+}
+
+func TestDiscloseRejectsCredentialWithoutID(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	e := echo.New()
+	RegisterRoutes(e, Config{Keys: keys})
+
+	credential, err := keys.Sign(jwt.MapClaims{
+		"org": "OrgA",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	assert.NoError(t, err)
+
+	rec := postTestDisclosure(e, credential, []byte("recipient"), "id-1")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestDiscloseRejectsUnknownSigningKey(t *testing.T) {
+	e, _ := setupTestRouter()
+	other, _ := keyring.New(keyring.Options{})
+
+	rec := postTestDisclosure(e, signTestCredential(t, other, "OrgA"), []byte("recipient"), "id-1")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestJWKS(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	e := echo.New()
+	RegisterRoutes(e, Config{Keys: keys})
+
+	credential := signTestCredential(t, keys, "OrgA")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var jwks types.JWKS
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jwks))
+	assert.Len(t, jwks.Keys, 1)
+
+	// The published key verifies issued credentials offline.
+	token, err := jwt.Parse(credential, func(token *jwt.Token) (any, error) {
+		assert.Equal(t, jwks.Keys[0].KeyID, token.Header["kid"])
+		return keyring.PublicKey(jwks.Keys[0])
+	})
+	assert.NoError(t, err)
+	assert.True(t, token.Valid)
+}
+
 func TestInboxChallengeAndAccessFlow(t *testing.T) {
-	e := setupTestRouter()
+	e, s := setupTestRouter()
 
 	var peerPrivateKey [32]byte
 	cryptoRand.Read(peerPrivateKey[:])
@@ -67,6 +522,9 @@ func TestInboxChallengeAndAccessFlow(t *testing.T) {
 	assert.NoError(t, err)
 	peerPublicKeyString := base64.RawURLEncoding.EncodeToString(peerPublicKey)
 
+	// Step 0: Register
+	s.PutRecipient(types.Recipient{Name: "Recipient", PublicKey: base64.StdEncoding.EncodeToString(peerPublicKey)})
+
 	// Step 1: Request a challenge
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/inbox/"+peerPublicKeyString+"/challenge", nil)
@@ -84,18 +542,12 @@ func TestInboxChallengeAndAccessFlow(t *testing.T) {
 	// Step 2: Simulate disclosure submissions from 3 orgs
 	orgs := []string{"OrgA", "OrgB", "OrgC"}
 	for _, org := range orgs {
-		if disclosures[string(peerPublicKey[:])] == nil {
-			disclosures[string(peerPublicKey[:])] = make(map[string]map[string]types.VerifiableShare)
-		}
-		if disclosures[string(peerPublicKey[:])][org] == nil {
-			disclosures[string(peerPublicKey[:])][org] = make(map[string]types.VerifiableShare)
-		}
 		for i := 0; i < 3; i++ {
 			id := fmt.Sprintf("id-%s-%d", org, i)
 			share := types.VerifiableShare{
 				Data: fmt.Sprintf("share-%s-%d", org, i),
 			}
-			disclosures[string(peerPublicKey[:])][org][id] = share
+			s.PutShare(peerPublicKey, store.Share{ID: id, Org: org, VerifiableShare: share})
 		}
 	}
 
@@ -117,7 +569,7 @@ func TestInboxChallengeAndAccessFlow(t *testing.T) {
 }
 
 func TestInboxDelete(t *testing.T) {
-	e := setupTestRouter()
+	e, s := setupTestRouter()
 
 	var peerPrivateKey [32]byte
 	cryptoRand.Read(peerPrivateKey[:])
@@ -128,6 +580,9 @@ func TestInboxDelete(t *testing.T) {
 
 	shareID := "share-id"
 
+	// Step 0: Register
+	s.PutRecipient(types.Recipient{Name: "Recipient", PublicKey: base64.StdEncoding.EncodeToString(peerPublicKey)})
+
 	// Step 1: Request a challenge
 	rec := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/inbox/"+peerPublicKeyString+"/challenge", nil)
@@ -144,9 +599,7 @@ func TestInboxDelete(t *testing.T) {
 
 	// Step 2: Simulate a share
 	org := "TestOrg"
-	disclosures[string(peerPublicKey[:])] = map[string]map[string]types.VerifiableShare{
-		org: {shareID: types.VerifiableShare{Data: "share-value"}},
-	}
+	s.PutShare(peerPublicKey, store.Share{ID: shareID, Org: org, VerifiableShare: types.VerifiableShare{Data: "share-value"}})
 
 	// Step 3: Encrypt token with shared key
 	privateKey := make([]byte, 32)
@@ -163,6 +616,7 @@ func TestInboxDelete(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	// Verify deletion
-	_, exists := disclosures[string(peerPublicKey[:])]
-	assert.False(t, exists)
+	shares, err := s.Shares(peerPublicKey)
+	assert.NoError(t, err)
+	assert.Empty(t, shares)
 }
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DefaultSessionLifetime is how long an inbox session lasts.
+	DefaultSessionLifetime = 5 * time.Minute
+
+	// sessionScope marks a JWT as an inbox session, so that neither a
+	// session nor a disclosure credential can stand in for the other.
+	sessionScope = "inbox"
+)
+
+// sessionAuth accepts an inbox session ("Session") for its key, or else an
+// answered challenge as challengeAuth does. It guards only the routes that
+// read and delete shares, so a session cannot change the registration.
+func (s *server) sessionAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	challenge := s.challengeAuth(next)
+	return func(c echo.Context) error {
+		authHeader := c.Request().Header.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Session ") {
+			return challenge(c)
+		}
+		err := s.verifySession(c.Param("key"), strings.TrimPrefix(authHeader, "Session "))
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			return apiErr
+		} else if err != nil {
+			return errInvalidSession.withMessage(err.Error())
+		}
+		return next(c)
+	}
+}
+
+// postInboxSession exchanges an answered challenge for a session token, so
+// a recipient can read and delete shares without a challenge per request.
+// It is behind challengeAuth, so a session cannot renew itself.
+func (s *server) postInboxSession(c echo.Context) error {
+	expiresAt := time.Now().Add(s.sessionLifetime)
+	session, err := s.keys.Sign(jwt.MapClaims{
+		"sub":   c.Param("key"),
+		"scope": sessionScope,
+		"exp":   expiresAt.Unix(),
+		"iat":   time.Now().Unix(),
+	})
+	if err != nil {
+		return errInternal.withMessage("could not sign session")
+	}
+
+	return c.JSON(http.StatusOK, types.SessionResponse{
+		Session:   session,
+		ExpiresAt: expiresAt.Unix(),
+	})
+}
+
+// verifySession checks that session is an unexpired inbox session for
+// publicKey, and that publicKey is still registered. A session outlives
+// neither deregistration nor a rotation away from its key.
+func (s *server) verifySession(publicKey string, session string) error {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(session, claims, s.keys.KeyFunc,
+		jwt.WithExpirationRequired(), jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil {
+		return fmt.Errorf("invalid session")
+	}
+
+	if scope, _ := claims["scope"].(string); scope != sessionScope {
+		return fmt.Errorf("invalid session")
+	}
+	if subject, _ := claims.GetSubject(); subject != publicKey {
+		return fmt.Errorf("session is for another key")
+	}
+
+	key, err := base64.RawURLEncoding.DecodeString(publicKey)
+	if err != nil {
+		return errInvalidKey
+	}
+	_, err = s.store.Recipient(base64.StdEncoding.EncodeToString(key))
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("session's key is no longer registered")
+	} else if err != nil {
+		return errInternal.withMessage("failed to load recipient")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (r testRecipient) session(t *testing.T, e *echo.Echo, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/inbox/"+r.urlKey()+"/session", nil)
+	req.Header.Set("Authorization", authorization)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func serveInbox(e *echo.Echo, method string, path string, authorization string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", authorization)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestInboxSession(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+	for _, id := range []string{"id-1", "id-2", "id-3"} {
+		s.PutShare(recipient.publicKey, store.Share{ID: id, Org: "OrgA"})
+	}
+
+	rec := recipient.session(t, e, recipient.inboxAuth(t, e))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp types.SessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.WithinDuration(t, time.Now().Add(DefaultSessionLifetime), time.Unix(resp.ExpiresAt, 0), time.Minute)
+	session := "Session " + resp.Session
+
+	// One session reads the inbox and deletes every share.
+	assert.Equal(t, http.StatusOK, serveInbox(e, http.MethodGet, "/inbox/"+recipient.urlKey(), session))
+	for _, id := range []string{"id-1", "id-2", "id-3"} {
+		assert.Equal(t, http.StatusOK, serveInbox(e, http.MethodDelete, "/inbox/"+recipient.urlKey()+"/"+id, session))
+	}
+	shares, err := s.Shares(recipient.publicKey)
+	assert.NoError(t, err)
+	assert.Empty(t, shares)
+
+	// A session is bound to its recipient and cannot renew itself.
+	other := newTestRecipient()
+	assert.Equal(t, http.StatusUnauthorized, serveInbox(e, http.MethodGet, "/inbox/"+other.urlKey(), session))
+	assertErrorResponse(t, recipient.session(t, e, session), http.StatusUnauthorized, types.ErrorInvalidAuthorization)
+}
+
+func TestInboxSession_OnlyForInbox(t *testing.T) {
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+
+	rec := recipient.session(t, e, recipient.inboxAuth(t, e))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp types.SessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	session := "Session " + resp.Session
+
+	// A session can neither rename, deregister nor rotate the recipient.
+	register := "/register/" + recipient.urlKey()
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, register},
+		{http.MethodDelete, register},
+		{http.MethodPost, register + "/rotate"},
+		{http.MethodPost, "/v1" + register},
+	} {
+		req := httptest.NewRequest(route.method, route.path, strings.NewReader(`{"name":"Mallory"}`))
+		req.Header.Set("Authorization", session)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assertErrorResponse(t, rec, http.StatusUnauthorized, types.ErrorInvalidAuthorization)
+	}
+
+	r, err := s.Recipient(base64.StdEncoding.EncodeToString(recipient.publicKey))
+	require.NoError(t, err)
+	assert.Equal(t, "Recipient", r.Name)
+}
+
+func TestInboxSession_Rejected(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Keys: keys})
+	recipient := newTestRecipient()
+	recipient.register(t, s)
+	inbox := "/inbox/" + recipient.urlKey()
+
+	expired, err := keys.Sign(jwt.MapClaims{
+		"sub":   recipient.urlKey(),
+		"scope": sessionScope,
+		"exp":   time.Now().Add(-time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, serveInbox(e, http.MethodGet, inbox, "Session "+expired))
+
+	// Disclosure credentials are not sessions, and sessions are not
+	// disclosure credentials.
+	credential := signTestCredential(t, keys, "OrgA")
+	assert.Equal(t, http.StatusUnauthorized, serveInbox(e, http.MethodGet, inbox, "Session "+credential))
+
+	rec := recipient.session(t, e, recipient.inboxAuth(t, e))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp types.SessionResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusUnauthorized, postTestDisclosure(e, resp.Session, recipient.publicKey, "id-1").Code)
+
+	other, _ := keyring.New(keyring.Options{})
+	forged, err := other.Sign(jwt.MapClaims{
+		"sub":   recipient.urlKey(),
+		"scope": sessionScope,
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, serveInbox(e, http.MethodGet, inbox, "Session "+forged))
+}
+
+func TestInboxSession_Revoked(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	s := store.NewMemory()
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: s, Keys: keys})
+
+	newSession := func(r testRecipient) string {
+		rec := r.session(t, e, r.inboxAuth(t, e))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp types.SessionResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return "Session " + resp.Session
+	}
+
+	// A session dies with its registration.
+	deregistered := newTestRecipient()
+	deregistered.register(t, s)
+	session := newSession(deregistered)
+	req := httptest.NewRequest(http.MethodDelete, "/register/"+deregistered.urlKey(), nil)
+	req.Header.Set("Authorization", deregistered.inboxAuth(t, e))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	req = httptest.NewRequest(http.MethodGet, "/inbox/"+deregistered.urlKey(), nil)
+	req.Header.Set("Authorization", session)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assertErrorResponse(t, rec, http.StatusUnauthorized, types.ErrorInvalidSession)
+
+	// And with the key it was issued to, once the registration rotates away.
+	from, to := newTestRecipient(), newTestRecipient()
+	from.register(t, s)
+	session = newSession(from)
+	require.Equal(t, http.StatusOK, rotate(t, e, from, to).Code)
+	assert.Equal(t, http.StatusUnauthorized, serveInbox(e, http.MethodGet, "/inbox/"+from.urlKey(), session))
+	assert.Equal(t, http.StatusUnauthorized, serveInbox(e, http.MethodDelete, "/inbox/"+from.urlKey()+"/id-1", session))
+}
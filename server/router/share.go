@@ -0,0 +1,59 @@
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	"golang.org/x/crypto/curve25519"
+)
+
+// minShareSize is the smallest protocol 1 share: the x coordinate, then one
+// byte per byte of an AES-GCM ciphertext, which carries a 12 byte nonce and
+// a 16 byte tag even around an empty disclosure.
+const minShareSize = 1 + 12 + 16
+
+// lowOrderCheckScalar is any scalar. X25519 multiplies low order points to
+// zero whatever the scalar, so a key that does not is a valid point.
+var lowOrderCheckScalar = []byte{
+	1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+	17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32,
+}
+
+// validateShare checks that share is well formed under protocol 1: a Shamir
+// share with a nonzero x coordinate, an ephemeral X25519 key that is not of
+// low order, and an HMAC-SHA256 commitment. The point cannot check that the
+// commitment matches, which takes the recipient's key.
+func validateShare(share types.VerifiableShare) error {
+	data, err := base64.StdEncoding.Strict().DecodeString(share.Data)
+	if err != nil {
+		return errInvalidShare.withMessage("share data is not valid base64")
+	}
+	if len(data) < minShareSize {
+		return errInvalidShare.withMessage(fmt.Sprintf("share data is %d bytes, at least %d expected", len(data), minShareSize))
+	}
+	if data[0] == 0 {
+		return errInvalidShare.withMessage("share x coordinate is zero")
+	}
+
+	ephemeralKey, err := base64.StdEncoding.Strict().DecodeString(share.EphemeralKey)
+	if err != nil {
+		return errInvalidShare.withMessage("ephemeral key is not valid base64")
+	}
+	if len(ephemeralKey) != curve25519.PointSize {
+		return errInvalidShare.withMessage(fmt.Sprintf("ephemeral key is %d bytes, %d expected", len(ephemeralKey), curve25519.PointSize))
+	}
+	if _, err := curve25519.X25519(lowOrderCheckScalar, ephemeralKey); err != nil {
+		return errInvalidShare.withMessage("ephemeral key is a low order point")
+	}
+
+	commitment, err := base64.StdEncoding.Strict().DecodeString(share.Commitment)
+	if err != nil {
+		return errInvalidShare.withMessage("commitment is not valid base64")
+	}
+	if len(commitment) != sha256.Size {
+		return errInvalidShare.withMessage(fmt.Sprintf("commitment is %d bytes, %d expected", len(commitment), sha256.Size))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateShare(t *testing.T) {
+	encode := base64.StdEncoding.EncodeToString
+	tests := []struct {
+		name    string
+		modify  func(*types.VerifiableShare)
+		message string
+	}{
+		{"valid", func(*types.VerifiableShare) {}, ""},
+		{"empty data", func(s *types.VerifiableShare) { s.Data = "" }, "share data is 0 bytes, at least 29 expected"},
+		{"data not base64", func(s *types.VerifiableShare) { s.Data = "!!!" }, "share data is not valid base64"},
+		{"data with trailing bits", func(s *types.VerifiableShare) { s.Data = "AR==" }, "share data is not valid base64"},
+		{"short data", func(s *types.VerifiableShare) { s.Data = encode(make([]byte, 28)) }, "share data is 28 bytes, at least 29 expected"},
+		{"zero x coordinate", func(s *types.VerifiableShare) { s.Data = encode(make([]byte, 29)) }, "share x coordinate is zero"},
+		{"ephemeral key not base64", func(s *types.VerifiableShare) { s.EphemeralKey = "!!!" }, "ephemeral key is not valid base64"},
+		{"short ephemeral key", func(s *types.VerifiableShare) { s.EphemeralKey = encode(make([]byte, 31)) }, "ephemeral key is 31 bytes, 32 expected"},
+		{"low order ephemeral key", func(s *types.VerifiableShare) { s.EphemeralKey = encode(make([]byte, 32)) }, "ephemeral key is a low order point"},
+		{"commitment not base64", func(s *types.VerifiableShare) { s.Commitment = "!!!" }, "commitment is not valid base64"},
+		{"long commitment", func(s *types.VerifiableShare) { s.Commitment = encode(make([]byte, 33)) }, "commitment is 33 bytes, 32 expected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			share := newTestShare("share")
+			tt.modify(&share)
+			err := validateShare(share)
+			if tt.message == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var apiErr *apiError
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, types.ErrorInvalidShare, apiErr.code)
+			assert.Equal(t, tt.message, apiErr.message)
+		})
+	}
+}
+
+func TestDisclose_InvalidShare(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	e := echo.New()
+	RegisterRoutes(e, Config{Keys: keys})
+	recipient := newTestRecipient()
+	credential := signTestCredential(t, keys, "OrgA")
+
+	share := newTestShare("share")
+	share.EphemeralKey = ""
+	body, _ := json.Marshal(types.DisclosureRequest{
+		ID:              "id-1",
+		Recipient:       base64.StdEncoding.EncodeToString(recipient.publicKey),
+		VerifiableShare: share,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/disclose", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+credential)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assertErrorResponse(t, rec, http.StatusBadRequest, types.ErrorInvalidShare)
+
+	// The credential was not spent on the rejected share.
+	assert.Equal(t, http.StatusOK, postTestDisclosure(e, credential, recipient.publicKey, "id-1").Code)
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"github.com/berkmancenter/rendezvous-point/orgs"
+	"github.com/berkmancenter/rendezvous-point/types"
+)
+
+const defaultThreshold = 3
+
+// Thresholds decides how many shares from one organization a recipient
+// must hold before any of them are released.
+type Thresholds struct {
+	// Default applies to organizations without an override. Defaults to 3.
+	Default int
+	// Orgs overrides Default for particular organizations, keyed by
+	// canonical name.
+	Orgs map[string]int
+}
+
+// normalized returns a copy of t with its defaults filled in and Orgs keyed
+// by orgs.Normalize.
+func (t Thresholds) normalized() Thresholds {
+	if t.Default <= 0 {
+		t.Default = defaultThreshold
+	}
+	byKey := make(map[string]int, len(t.Orgs))
+	for name, threshold := range t.Orgs {
+		byKey[orgs.Normalize(name)] = threshold
+	}
+	t.Orgs = byKey
+	return t
+}
+
+// For returns the threshold for shares from the organization with the given
+// normalized name: the operator's policy for it, raised to the recipient's
+// own minimum if that is higher.
+func (t Thresholds) For(orgKey string, recipient *types.Recipient) int {
+	threshold := t.Default
+	if override, ok := t.Orgs[orgKey]; ok {
+		threshold = override
+	}
+	if recipient != nil && recipient.Threshold > threshold {
+		threshold = recipient.Threshold
+	}
+	return threshold
+}
@@ -0,0 +1,300 @@
+package router
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/blindrsa"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// tokenEpoch is how long each organization's blind signing key issues
+	// tokens. Tokens are accepted during their epoch and the next, so none
+	// outlives credentialLifetime.
+	tokenEpoch = credentialLifetime / 2
+
+	defaultTokenKeyBits = 2048
+	defaultMaxTokenKeys = 1000
+
+	// tokenKeySpares is how many keys are generated ahead of demand.
+	tokenKeySpares = 2
+
+	// minTokenMessage is the shortest token message accepted. Messages are
+	// signed without randomization, so they must be unguessable.
+	minTokenMessage = 16
+)
+
+// tokenScope is where tokens are spent. Unlike JWT credentials they are
+// single use across all recipients, since nothing ties a redemption back
+// to the requester who could otherwise be limited per recipient.
+var tokenScope = []byte("tokens")
+
+// tokenKeys holds a blind signing key per organization and epoch. Keys are
+// persisted in the store, so unredeemed tokens survive a restart, and are
+// generated ahead of demand in the background, so a request from a new
+// organization does not pay for RSA key generation. At most limit
+// organizations get a key each epoch.
+type tokenKeys struct {
+	bits  int
+	limit int
+	store store.Store
+	now   func() time.Time
+
+	start  sync.Once
+	spares chan *rsa.PrivateKey
+
+	mu     sync.Mutex
+	keys   map[tokenKeyID]*rsa.PrivateKey // parsed keys read from the store
+	purged int64                          // epochs before this are purged
+}
+
+type tokenKeyID struct {
+	org   string
+	epoch int64
+}
+
+func newTokenKeys(s store.Store, bits int, limit int) *tokenKeys {
+	if bits == 0 {
+		bits = defaultTokenKeyBits
+	}
+	if limit == 0 {
+		limit = defaultMaxTokenKeys
+	}
+	return &tokenKeys{
+		bits:   bits,
+		limit:  limit,
+		store:  s,
+		now:    time.Now,
+		spares: make(chan *rsa.PrivateKey, tokenKeySpares),
+		keys:   map[tokenKeyID]*rsa.PrivateKey{},
+	}
+}
+
+func (k *tokenKeys) epoch() int64 {
+	return k.now().Unix() / int64(tokenEpoch/time.Second)
+}
+
+// generate keeps spares full. It starts with the first token key request,
+// so servers that never issue tokens never generate keys.
+func (k *tokenKeys) generate() {
+	for {
+		key, err := rsa.GenerateKey(rand.Reader, k.bits)
+		if err != nil {
+			log.Printf("generate token key: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		k.spares <- key
+	}
+}
+
+// current returns the key signing tokens for org in the current epoch,
+// taking a spare key if org has none yet.
+func (k *tokenKeys) current(ctx context.Context, org string) (int64, *rsa.PrivateKey, error) {
+	epoch := k.epoch()
+	if key, err := k.load(org, epoch); key != nil || err != nil {
+		return epoch, key, err
+	}
+
+	k.start.Do(func() { go k.generate() })
+	var spare *rsa.PrivateKey
+	select {
+	case spare = <-k.spares:
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	id := tokenKeyID{org: org, epoch: epoch}
+	if key, ok := k.keys[id]; ok {
+		// Another request for org got there first.
+		k.returnSpare(spare)
+		return epoch, key, nil
+	}
+	if err := k.store.PutTokenKey(org, epoch, x509.MarshalPKCS1PrivateKey(spare), k.limit); err != nil {
+		k.returnSpare(spare)
+		return 0, nil, err
+	}
+	k.keys[id] = spare
+	return epoch, spare, nil
+}
+
+func (k *tokenKeys) returnSpare(key *rsa.PrivateKey) {
+	select {
+	case k.spares <- key:
+	default:
+	}
+}
+
+// lookup returns the key that signed tokens for org in epoch, or nil if
+// there is none or its tokens have expired.
+func (k *tokenKeys) lookup(org string, epoch int64) (*rsa.PrivateKey, error) {
+	if current := k.epoch(); epoch < current-1 || epoch > current {
+		return nil, nil
+	}
+	return k.load(org, epoch)
+}
+
+// load returns the stored key for org in epoch, or nil if there is none,
+// purging keys whose tokens have all expired.
+func (k *tokenKeys) load(org string, epoch int64) (*rsa.PrivateKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if current := k.epoch(); current-1 > k.purged {
+		for id := range k.keys {
+			if id.epoch < current-1 {
+				delete(k.keys, id)
+			}
+		}
+		if _, err := k.store.PurgeTokenKeys(current - 1); err != nil {
+			return nil, err
+		}
+		k.purged = current - 1
+	}
+
+	id := tokenKeyID{org: org, epoch: epoch}
+	if key, ok := k.keys[id]; ok {
+		return key, nil
+	}
+	der, err := k.store.TokenKey(org, epoch)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS1PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	k.keys[id] = key
+	return key, nil
+}
+
+func tokenExpiry(epoch int64) time.Time {
+	return time.Unix((epoch+2)*int64(tokenEpoch/time.Second), 0)
+}
+
+func (s *server) currentTokenKey(c echo.Context, org string) (int64, *rsa.PrivateKey, error) {
+	epoch, key, err := s.tokens.current(c.Request().Context(), org)
+	if errors.Is(err, store.ErrLimit) {
+		return 0, nil, errTooManyTokenKeys
+	} else if err != nil {
+		return 0, nil, errInternal.withMessage("could not load token key")
+	}
+	return epoch, key, nil
+}
+
+func (s *server) getTokenKey(c echo.Context) error {
+	org, err := s.organization(c)
+	if err != nil {
+		return errOrganizationUnknown
+	}
+
+	epoch, key, err := s.currentTokenKey(c, org)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return errInternal.withMessage("could not encode token key")
+	}
+
+	return c.JSON(http.StatusOK, types.TokenKeyResponse{
+		Organization: org,
+		Epoch:        epoch,
+		PublicKey:    base64.StdEncoding.EncodeToString(der),
+	})
+}
+
+func (s *server) postCredential(c echo.Context) error {
+	var req types.TokenRequest
+	if err := json.NewDecoder(c.Request().Body).Decode(&req); err != nil {
+		return errInvalidBody
+	}
+	blinded, err := base64.StdEncoding.DecodeString(req.BlindedMessage)
+	if err != nil {
+		return errInvalidBody.withMessage("invalid blinded message encoding")
+	}
+
+	org, err := s.organization(c)
+	if err != nil {
+		return errOrganizationUnknown
+	}
+
+	epoch, key, err := s.currentTokenKey(c, org)
+	if err != nil {
+		return err
+	}
+	if req.Epoch != epoch {
+		return errTokenKeyRotated
+	}
+
+	blindSig, err := blindrsa.BlindSign(key, blinded)
+	if errors.Is(err, blindrsa.ErrInvalidMessage) {
+		return errInvalidBody.withMessage("invalid blinded message")
+	} else if err != nil {
+		return errInternal.withMessage("could not sign token")
+	}
+
+	return c.JSON(http.StatusOK, types.TokenResponse{
+		Organization:   org,
+		Epoch:          epoch,
+		BlindSignature: base64.StdEncoding.EncodeToString(blindSig),
+	})
+}
+
+func (s *server) verifyToken(encoded string) (*credential, error) {
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64")
+	}
+
+	var token types.Token
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return nil, fmt.Errorf("invalid json")
+	}
+
+	key, err := s.tokens.lookup(token.Organization, token.Epoch)
+	if err != nil {
+		return nil, errInternal.withMessage("failed to load token key")
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown or expired token key")
+	}
+
+	message, err := base64.StdEncoding.DecodeString(token.Message)
+	if err != nil || len(message) < minTokenMessage {
+		return nil, fmt.Errorf("invalid token message")
+	}
+	signature, err := base64.StdEncoding.DecodeString(token.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+	if err := blindrsa.Verify(&key.PublicKey, message, signature); err != nil {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	return &credential{
+		org: token.Organization,
+		// Re-encode, so differently encoded copies of a token spend alike.
+		id:      base64.StdEncoding.EncodeToString(message),
+		expires: tokenExpiry(token.Epoch),
+		scope:   tokenScope,
+	}, nil
+}
@@ -0,0 +1,200 @@
+package router
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/blindrsa"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fetchTestTokenKey asks for the blind signing key of the organization at
+// 8.8.8.8.
+func fetchTestTokenKey(t *testing.T, e *echo.Echo) (*rsa.PublicKey, int64) {
+	req := httptest.NewRequest(http.MethodGet, "/credential/token-key", nil)
+	req.RemoteAddr = "8.8.8.8:1234"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp types.TokenKeyResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "Google LLC", resp.Organization)
+
+	der, err := base64.StdEncoding.DecodeString(resp.PublicKey)
+	require.NoError(t, err)
+	pub, err := x509.ParsePKIXPublicKey(der)
+	require.NoError(t, err)
+	return pub.(*rsa.PublicKey), resp.Epoch
+}
+
+// postTestBlindedMessage asks the server to sign a blinded message as the
+// requester at 8.8.8.8.
+func postTestBlindedMessage(e *echo.Echo, epoch int64, blinded []byte) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(types.TokenRequest{
+		Epoch:          epoch,
+		BlindedMessage: base64.StdEncoding.EncodeToString(blinded),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/credential", bytes.NewReader(body))
+	req.RemoteAddr = "8.8.8.8:1234"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// issueTestToken runs the client side of token issuance.
+func issueTestToken(t *testing.T, e *echo.Echo) types.Token {
+	pub, epoch := fetchTestTokenKey(t, e)
+
+	message := make([]byte, 32)
+	rand.Read(message)
+	blinded, state, err := blindrsa.Blind(pub, message, rand.Reader)
+	require.NoError(t, err)
+
+	rec := postTestBlindedMessage(e, epoch, blinded)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp types.TokenResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	blindSig, err := base64.StdEncoding.DecodeString(resp.BlindSignature)
+	require.NoError(t, err)
+
+	sig, err := blindrsa.Finalize(pub, message, state, blindSig)
+	require.NoError(t, err)
+	return types.Token{
+		Organization: resp.Organization,
+		Epoch:        resp.Epoch,
+		Message:      base64.StdEncoding.EncodeToString(message),
+		Signature:    base64.StdEncoding.EncodeToString(sig),
+	}
+}
+
+func tokenAuth(token types.Token) string {
+	payload, _ := json.Marshal(token)
+	return "Token " + base64.StdEncoding.EncodeToString(payload)
+}
+
+func setupTokenTestServer() (*server, *echo.Echo) {
+	s := newServer(Config{Store: store.NewMemory(), Resolver: testResolver, TokenKeyBits: 1024})
+	e := echo.New()
+	s.register(e)
+	return s, e
+}
+
+func TestTokenIssueAndRedeem(t *testing.T) {
+	s, e := setupTokenTestServer()
+	recipient := newTestRecipient()
+
+	token := issueTestToken(t, e)
+	rec := postTestDisclosureAuth(e, tokenAuth(token), recipient.publicKey, "id-1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	shares, err := s.store.Shares(recipient.publicKey)
+	assert.NoError(t, err)
+	require.Len(t, shares, 1)
+	assert.Equal(t, "Google LLC", shares[0].Org)
+
+	// Tokens are single use across recipients.
+	rec = postTestDisclosureAuth(e, tokenAuth(token), recipient.publicKey, "id-2")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = postTestDisclosureAuth(e, tokenAuth(token), newTestRecipient().publicKey, "id-2")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	// A fresh token is accepted.
+	rec = postTestDisclosureAuth(e, tokenAuth(issueTestToken(t, e)), recipient.publicKey, "id-2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestTokenRejectsForgery(t *testing.T) {
+	_, e := setupTokenTestServer()
+	recipient := newTestRecipient()
+	token := issueTestToken(t, e)
+
+	tampered := token
+	sig, _ := base64.StdEncoding.DecodeString(token.Signature)
+	sig[0] ^= 1
+	tampered.Signature = base64.StdEncoding.EncodeToString(sig)
+	assert.Equal(t, http.StatusUnauthorized, postTestDisclosureAuth(e, tokenAuth(tampered), recipient.publicKey, "id-1").Code)
+
+	otherOrg := token
+	otherOrg.Organization = "Example Corp"
+	assert.Equal(t, http.StatusUnauthorized, postTestDisclosureAuth(e, tokenAuth(otherOrg), recipient.publicKey, "id-1").Code)
+
+	otherMessage := token
+	otherMessage.Message = base64.StdEncoding.EncodeToString(make([]byte, 32))
+	assert.Equal(t, http.StatusUnauthorized, postTestDisclosureAuth(e, tokenAuth(otherMessage), recipient.publicKey, "id-1").Code)
+
+	assert.Equal(t, http.StatusOK, postTestDisclosureAuth(e, tokenAuth(token), recipient.publicKey, "id-1").Code)
+}
+
+func TestTokenEpochs(t *testing.T) {
+	s, e := setupTokenTestServer()
+	now := time.Unix(1700000000, 0)
+	s.tokens.now = func() time.Time { return now }
+	recipient := newTestRecipient()
+
+	token := issueTestToken(t, e)
+	pub, epoch := fetchTestTokenKey(t, e)
+
+	// A key fetched before rotation can no longer be used to issue.
+	now = now.Add(tokenEpoch)
+	blinded, _, err := blindrsa.Blind(pub, make([]byte, 32), rand.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, postTestBlindedMessage(e, epoch, blinded).Code)
+
+	// Issued tokens are redeemable during the next epoch, and not after.
+	second := issueTestToken(t, e)
+	assert.Equal(t, http.StatusOK, postTestDisclosureAuth(e, tokenAuth(token), recipient.publicKey, "id-1").Code)
+
+	now = now.Add(tokenEpoch)
+	fetchTestTokenKey(t, e)
+	assert.Equal(t, http.StatusOK, postTestDisclosureAuth(e, tokenAuth(second), recipient.publicKey, "id-2").Code)
+
+	now = now.Add(tokenEpoch)
+	assert.Equal(t, http.StatusUnauthorized, postTestDisclosureAuth(e, tokenAuth(second), recipient.publicKey, "id-3").Code)
+}
+
+func TestTokenKeysSurviveRestart(t *testing.T) {
+	s := store.NewMemory()
+	recipient := newTestRecipient()
+
+	before := echo.New()
+	RegisterRoutes(before, Config{Store: s, Resolver: testResolver, TokenKeyBits: 1024})
+	token := issueTestToken(t, before)
+	pub, _ := fetchTestTokenKey(t, before)
+
+	after := echo.New()
+	RegisterRoutes(after, Config{Store: s, Resolver: testResolver, TokenKeyBits: 1024})
+	restored, _ := fetchTestTokenKey(t, after)
+	assert.True(t, pub.Equal(restored))
+	assert.Equal(t, http.StatusOK, postTestDisclosureAuth(after, tokenAuth(token), recipient.publicKey, "id-1").Code)
+}
+
+func TestTokenKeyLimit(t *testing.T) {
+	s := newServer(Config{Store: store.NewMemory(), Resolver: testResolver, TokenKeyBits: 1024, MaxTokenKeys: 1})
+	e := echo.New()
+	s.register(e)
+	require.NoError(t, s.store.PutTokenKey("Example Corp", s.tokens.epoch(), []byte("key"), 1))
+
+	req := httptest.NewRequest(http.MethodGet, "/credential/token-key", nil)
+	req.RemoteAddr = "8.8.8.8:1234"
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assertErrorResponse(t, rec, http.StatusServiceUnavailable, types.ErrorTooManyTokenKeys)
+
+	// The next epoch starts over.
+	now := time.Now().Add(tokenEpoch)
+	s.tokens.now = func() time.Time { return now }
+	fetchTestTokenKey(t, e)
+}
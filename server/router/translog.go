@@ -0,0 +1,188 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// maxLogEntries caps how many entries one request can fetch.
+const maxLogEntries = 1000
+
+// logListing logs a change to the public directory: a recipient that is
+// approved after the event, or was before it. Recipients awaiting review
+// or rejected are not published, and a delisting records the listing as
+// it was, with only the new status.
+func (s *server) logListing(action types.LogAction, before *types.Recipient, after *types.Recipient, previousKey string) error {
+	switch {
+	case after != nil && after.Status == types.RecipientApproved:
+		return s.logEvent(action, *after, previousKey)
+	case before != nil && before.Status == types.RecipientApproved:
+		delisted := *before
+		if after != nil {
+			delisted.Status = after.Status
+		}
+		return s.logEvent(action, delisted, previousKey)
+	}
+	return nil
+}
+
+// logEvent appends a registration event to the transparency log.
+func (s *server) logEvent(action types.LogAction, r types.Recipient, previousKey string) error {
+	r.Notes = ""
+	entry, err := json.Marshal(types.LogEntry{
+		Action:      action,
+		Recipient:   r,
+		PreviousKey: previousKey,
+		Time:        time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := s.store.AppendLog(entry); err != nil {
+		return err
+	}
+	_, err = s.syncLog()
+	return err
+}
+
+// syncLog hashes the entries appended to the store's log since the last
+// call into s.log, returning the log's size.
+func (s *server) syncLog() (uint64, error) {
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+
+	size, err := s.store.LogSize()
+	if err != nil {
+		return 0, err
+	}
+	if hashed := s.log.Size(); hashed < size {
+		entries, err := s.store.LogEntries(hashed, size)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			s.log.Append(entry)
+		}
+	}
+	return s.log.Size(), nil
+}
+
+// uintParam parses an optional unsigned query parameter.
+func uintParam(c echo.Context, name string, fallback uint64) (uint64, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+func (s *server) getLogHead(c echo.Context) error {
+	size, err := s.syncLog()
+	if err != nil {
+		return errInternal.withMessage("failed to load log")
+	}
+	head, err := s.log.Head(size)
+	if err != nil {
+		return errInternal.withMessage("failed to load log")
+	}
+
+	head.IssuedAt = jwt.NewNumericDate(time.Now())
+	signed, err := s.logKey.Sign(head)
+	if err != nil {
+		return errInternal.withMessage("could not sign tree head")
+	}
+	return c.JSON(http.StatusOK, types.TreeHeadResponse{TreeHead: signed})
+}
+
+func (s *server) getLogEntries(c echo.Context) error {
+	start, err := uintParam(c, "start", 0)
+	if err != nil {
+		return errInvalidParameter.withMessage("invalid start")
+	}
+	end, err := uintParam(c, "end", start+maxLogEntries)
+	if err != nil || end < start {
+		return errInvalidParameter.withMessage("invalid end")
+	}
+
+	entries, err := s.store.LogEntries(start, min(end, start+maxLogEntries))
+	if err != nil {
+		return errInternal.withMessage("failed to load log")
+	}
+	if entries == nil {
+		entries = [][]byte{}
+	}
+	return c.JSON(http.StatusOK, types.LogEntriesResponse{Entries: entries})
+}
+
+// getInclusionProof proves the entry at index is in the tree of size,
+// which defaults to the current size.
+func (s *server) getInclusionProof(c echo.Context) error {
+	current, err := s.syncLog()
+	if err != nil {
+		return errInternal.withMessage("failed to load log")
+	}
+	index, err := uintParam(c, "index", 0)
+	if err != nil || c.QueryParam("index") == "" {
+		return errInvalidParameter.withMessage("invalid index")
+	}
+	size, err := uintParam(c, "size", current)
+	if err != nil || size > current || index >= size {
+		return errInvalidParameter.withMessage("invalid size")
+	}
+
+	entries, err := s.store.LogEntries(index, index+1)
+	if err != nil || len(entries) != 1 {
+		return errInternal.withMessage("failed to load log")
+	}
+	proof, err := s.log.InclusionProof(index, size)
+	if err != nil {
+		return errInvalidParameter.withMessage(err.Error())
+	}
+	if proof == nil {
+		proof = [][]byte{}
+	}
+
+	return c.JSON(http.StatusOK, types.InclusionProofResponse{
+		Index:    index,
+		TreeSize: size,
+		Entry:    entries[0],
+		Proof:    proof,
+	})
+}
+
+// getConsistencyProof proves the tree of size first is a prefix of the
+// tree of size second, which defaults to the current size.
+func (s *server) getConsistencyProof(c echo.Context) error {
+	current, err := s.syncLog()
+	if err != nil {
+		return errInternal.withMessage("failed to load log")
+	}
+	first, err := uintParam(c, "first", 0)
+	if err != nil {
+		return errInvalidParameter.withMessage("invalid first")
+	}
+	second, err := uintParam(c, "second", current)
+	if err != nil || second > current || first > second {
+		return errInvalidParameter.withMessage("invalid second")
+	}
+
+	proof, err := s.log.ConsistencyProof(first, second)
+	if err != nil {
+		return errInvalidParameter.withMessage(err.Error())
+	}
+	if proof == nil {
+		proof = [][]byte{}
+	}
+
+	return c.JSON(http.StatusOK, types.ConsistencyProofResponse{
+		First:  first,
+		Second: second,
+		Proof:  proof,
+	})
+}
@@ -0,0 +1,138 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/directory"
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/berkmancenter/rendezvous-point/store"
+	"github.com/berkmancenter/rendezvous-point/translog"
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestJSON(t *testing.T, e *echo.Echo, path string, v any) {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+}
+
+func getTestTreeHead(t *testing.T, e *echo.Echo) *translog.TreeHead {
+	var response types.TreeHeadResponse
+	getTestJSON(t, e, "/log/head", &response)
+	var jwks types.JWKS
+	getTestJSON(t, e, "/.well-known/log-jwks.json", &jwks)
+	head, err := translog.ParseTreeHead(response.TreeHead, directory.KeyFunc(jwks))
+	require.NoError(t, err)
+	return head
+}
+
+func TestTransparencyLog(t *testing.T) {
+	keys, _ := keyring.New(keyring.Options{})
+	e := echo.New()
+	RegisterRoutes(e, Config{Store: store.NewMemory(), Keys: keys, AdminToken: testAdminToken})
+
+	empty := getTestTreeHead(t, e)
+	assert.Zero(t, empty.Size)
+
+	// Registrations awaiting review or rejected are not published.
+	old, next, rejected := newTestRecipient(), newTestRecipient(), newTestRecipient()
+	require.Equal(t, http.StatusOK, old.postRegister(t, e, `{"name":"Legal Team"}`).Code)
+	require.Equal(t, http.StatusOK, rejected.postRegister(t, e, `{"name":"Unlisted"}`).Code)
+	require.Equal(t, http.StatusOK, adminRequest(e, http.MethodPut, "/admin/recipients/"+rejected.urlKey(), `{"status":"rejected"}`).Code)
+	assert.Zero(t, getTestTreeHead(t, e).Size)
+
+	require.Equal(t, http.StatusOK, adminRequest(e, http.MethodPut, "/admin/recipients/"+old.urlKey(), `{"status":"approved","notes":"private"}`).Code)
+	first := getTestTreeHead(t, e)
+	assert.Equal(t, uint64(1), first.Size)
+
+	// Renaming delists the recipient under its old name until the new one
+	// is approved.
+	require.Equal(t, http.StatusOK, old.postRegister(t, e, `{"name":"Legal Desk"}`).Code)
+	require.Equal(t, http.StatusOK, adminRequest(e, http.MethodPut, "/admin/recipients/"+old.urlKey(), `{"status":"approved"}`).Code)
+	require.Equal(t, http.StatusOK, rotate(t, e, old, next).Code)
+	req := httptest.NewRequest(http.MethodDelete, "/register/"+next.urlKey(), nil)
+	req.Header.Set("Authorization", next.inboxAuth(t, e))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	head := getTestTreeHead(t, e)
+	require.Equal(t, uint64(5), head.Size)
+	root, err := head.Root()
+	require.NoError(t, err)
+
+	var entries types.LogEntriesResponse
+	getTestJSON(t, e, "/log/entries", &entries)
+	require.Len(t, entries.Entries, 5)
+
+	oldKey := base64.StdEncoding.EncodeToString(old.publicKey)
+	newKey := base64.StdEncoding.EncodeToString(next.publicKey)
+	want := []struct {
+		action types.LogAction
+		key    string
+		name   string
+		status types.RecipientStatus
+	}{
+		{types.LogReview, oldKey, "Legal Team", types.RecipientApproved},
+		{types.LogUpdate, oldKey, "Legal Team", types.RecipientPending},
+		{types.LogReview, oldKey, "Legal Desk", types.RecipientApproved},
+		{types.LogRotate, newKey, "Legal Desk", types.RecipientApproved},
+		{types.LogDeregister, newKey, "Legal Desk", types.RecipientApproved},
+	}
+	for i, raw := range entries.Entries {
+		var entry types.LogEntry
+		require.NoError(t, json.Unmarshal(raw, &entry))
+		assert.Equal(t, want[i].action, entry.Action)
+		assert.Equal(t, want[i].key, entry.Recipient.PublicKey)
+		assert.Equal(t, want[i].name, entry.Recipient.Name)
+		assert.Equal(t, want[i].status, entry.Recipient.Status)
+		assert.Empty(t, entry.Recipient.Notes)
+		assert.NotZero(t, entry.Time)
+		if entry.Action == types.LogRotate {
+			assert.Equal(t, oldKey, entry.PreviousKey)
+		}
+
+		var proof types.InclusionProofResponse
+		getTestJSON(t, e, fmt.Sprintf("/log/proof/inclusion?index=%d", i), &proof)
+		assert.Equal(t, raw, proof.Entry)
+		assert.NoError(t, translog.VerifyInclusion(uint64(i), head.Size, translog.LeafHash(proof.Entry), proof.Proof, root))
+	}
+
+	var consistency types.ConsistencyProofResponse
+	getTestJSON(t, e, "/log/proof/consistency?first=1", &consistency)
+	firstRoot, _ := first.Root()
+	assert.NoError(t, translog.VerifyConsistency(1, 5, firstRoot, root, consistency.Proof))
+
+	var page types.LogEntriesResponse
+	getTestJSON(t, e, "/log/entries?start=3&end=4", &page)
+	assert.Equal(t, entries.Entries[3:4], page.Entries)
+}
+
+func TestTransparencyLog_InvalidParams(t *testing.T) {
+	e, s := setupTestRouter()
+	_, err := s.AppendLog([]byte("entry"))
+	require.NoError(t, err)
+
+	for _, path := range []string{
+		"/log/proof/inclusion",
+		"/log/proof/inclusion?index=1",
+		"/log/proof/inclusion?index=0&size=2",
+		"/log/proof/consistency?first=2",
+		"/log/proof/consistency?second=2",
+		"/log/entries?start=x",
+		"/log/entries?start=2&end=1",
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, path)
+	}
+}
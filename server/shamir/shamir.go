@@ -0,0 +1,125 @@
+// Package shamir splits a secret into shares, any threshold of which
+// recover it, with Shamir's scheme over GF(2^8).
+//
+// Shares are laid out as the iOS app's SwiftySSS lays them out: one byte of
+// x coordinate, then one byte of y per byte of the secret, in the field
+// reduced by the AES polynomial x^8 + x^4 + x^3 + x + 1. Shares from either
+// side combine on the other, which the client package's shared disclosure
+// vector and the app's CompatibilityTests check from both sides.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidThreshold = errors.New("threshold must be between 1 and the number of shares")
+	ErrTooManyShares    = errors.New("at most 255 shares")
+	ErrInvalidShares    = errors.New("invalid shares")
+)
+
+// Split divides secret into n shares, any threshold of which recover it.
+func Split(secret []byte, n int, threshold int) ([][]byte, error) {
+	if n > 255 {
+		return nil, ErrTooManyShares
+	}
+	if threshold < 1 || threshold > n {
+		return nil, ErrInvalidThreshold
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, 1, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	// Each byte of the secret is the constant term of its own random
+	// polynomial of degree threshold-1.
+	coefficients := make([]byte, threshold)
+	for _, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i] = append(shares[i], evaluate(coefficients, shares[i][0]))
+		}
+	}
+	return shares, nil
+}
+
+// Combine recovers a secret from its shares. Shares from fewer than the
+// threshold combine without error into the wrong secret, so callers must
+// check the result, as authenticated encryption does.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("%w: no shares", ErrInvalidShares)
+	}
+	length := len(shares[0])
+	seen := make(map[byte]bool, len(shares))
+	for _, share := range shares {
+		if len(share) < 2 || len(share) != length {
+			return nil, fmt.Errorf("%w: lengths differ", ErrInvalidShares)
+		}
+		if share[0] == 0 || seen[share[0]] {
+			return nil, fmt.Errorf("%w: x coordinates must be distinct and nonzero", ErrInvalidShares)
+		}
+		seen[share[0]] = true
+	}
+
+	// Lagrange interpolation at zero: each share's y is weighted by the
+	// product of x_j / (x_j - x_i) over the other shares. Subtraction is
+	// addition in GF(2^8).
+	weights := make([]byte, len(shares))
+	for i, share := range shares {
+		weight := byte(1)
+		for j, other := range shares {
+			if i != j {
+				weight = mul(weight, div(other[0], other[0]^share[0]))
+			}
+		}
+		weights[i] = weight
+	}
+
+	secret := make([]byte, length-1)
+	for k := range secret {
+		var b byte
+		for i, share := range shares {
+			b ^= mul(weights[i], share[k+1])
+		}
+		secret[k] = b
+	}
+	return secret, nil
+}
+
+// evaluate returns the polynomial with the given coefficients, constant
+// term first, at x.
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+// mul multiplies in GF(2^8) without branching on secret values.
+func mul(a byte, b byte) byte {
+	var product byte
+	for range 8 {
+		product ^= -(b & 1) & a
+		a = a<<1 ^ -(a>>7)&0x1b
+		b >>= 1
+	}
+	return product
+}
+
+// div divides in GF(2^8), multiplying by the inverse b^254.
+func div(a byte, b byte) byte {
+	inverse := b
+	for range 6 {
+		inverse = mul(mul(inverse, inverse), b)
+	}
+	return mul(a, mul(inverse, inverse))
+}
@@ -0,0 +1,82 @@
+package shamir
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestField(t *testing.T) {
+	// FIPS 197, section 4.2.
+	assert.Equal(t, byte(0xc1), mul(0x57, 0x83))
+
+	for a := 1; a < 256; a++ {
+		assert.Equal(t, byte(1), div(byte(a), byte(a)))
+		assert.Equal(t, byte(0x57), mul(div(0x57, byte(a)), byte(a)))
+	}
+}
+
+func TestCombine_Vector(t *testing.T) {
+	// "hi" split with threshold 2 and first-degree coefficients 0x11 and
+	// 0x22.
+	var shares [][]byte
+	for _, s := range []string{"01794b", "024a2d", "035b0f"} {
+		share, _ := hex.DecodeString(s)
+		shares = append(shares, share)
+	}
+
+	for _, pair := range [][2]int{{0, 1}, {0, 2}, {2, 1}} {
+		secret, err := Combine([][]byte{shares[pair[0]], shares[pair[1]]})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hi"), secret)
+	}
+}
+
+func TestSplitAndCombine(t *testing.T) {
+	secret := []byte("The password is swordfish")
+
+	shares, err := Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+	for i, share := range shares {
+		assert.Equal(t, byte(i+1), share[0])
+		assert.Len(t, share, len(secret)+1)
+	}
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var picked [][]byte
+		for _, i := range subset {
+			picked = append(picked, shares[i])
+		}
+		combined, err := Combine(picked)
+		require.NoError(t, err)
+		assert.Equal(t, secret, combined)
+	}
+
+	// Too few shares combine into something else.
+	combined, err := Combine(shares[:2])
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, combined)
+}
+
+func TestSplit_Invalid(t *testing.T) {
+	_, err := Split([]byte("secret"), 3, 4)
+	assert.ErrorIs(t, err, ErrInvalidThreshold)
+	_, err = Split([]byte("secret"), 3, 0)
+	assert.ErrorIs(t, err, ErrInvalidThreshold)
+	_, err = Split([]byte("secret"), 256, 2)
+	assert.ErrorIs(t, err, ErrTooManyShares)
+}
+
+func TestCombine_Invalid(t *testing.T) {
+	_, err := Combine(nil)
+	assert.ErrorIs(t, err, ErrInvalidShares)
+	_, err = Combine([][]byte{{1, 2, 3}, {2, 3}})
+	assert.ErrorIs(t, err, ErrInvalidShares)
+	_, err = Combine([][]byte{{1, 2}, {1, 3}})
+	assert.ErrorIs(t, err, ErrInvalidShares)
+	_, err = Combine([][]byte{{0, 2}, {1, 3}})
+	assert.ErrorIs(t, err, ErrInvalidShares)
+}
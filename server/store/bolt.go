@@ -0,0 +1,800 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	recipientsBucket  = []byte("recipients")
+	challengesBucket  = []byte("challenges")
+	sharesBucket      = []byte("shares")
+	credentialsBucket = []byte("credentials")
+	metaBucket        = []byte("meta")
+	logBucket         = []byte("log")
+	tokenKeysBucket   = []byte("token-keys")
+
+	sealerCheckKey = []byte("sealer-check")
+	epochKey       = []byte("directory-epoch")
+	hashKey        = []byte("directory-hash")
+	sealerCheck    = []byte("rendezvous")
+)
+
+// Bolt is a Store backed by a single bbolt database file. Every write is
+// committed in its own transaction and fsynced before returning, so state
+// survives restarts and crashes.
+//
+// Layout:
+//
+//	recipients:  publicKeyBase64 -> Recipient
+//	challenges:  publicKeyBase64 -> nonce -> Challenge
+//	shares:      publicKey -> Index(disclosureID) -> Seal(Share)
+//	credentials: scope -> credential ID -> expiry (big endian Unix seconds)
+//	meta:        sealer-check -> Seal("rendezvous")
+//	             directory-epoch -> epoch (big endian)
+//	             directory-hash -> hash of the directory published in that epoch
+//	log:         index (big endian) -> entry
+//	token-keys:  epoch (big endian) -> Index(org) -> Seal(tokenKeyRecord)
+//
+// When opened with a Sealer, share records (org, disclosure ID and share
+// data) and token keys, with their organizations, are encrypted at rest.
+type Bolt struct {
+	db     *bolt.DB
+	sealer *Sealer
+}
+
+// OpenBolt opens (creating if needed) the database at path, encrypting
+// shares with sealer if it is not nil. An existing database must be opened
+// with the key it was last sealed under. Only one process may hold the
+// database open at a time.
+func OpenBolt(path string, sealer *Sealer) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recipientsBucket, challengesBucket, sharesBucket, credentialsBucket, metaBucket, logBucket, tokenKeysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return checkSealer(tx, sealer)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init %s: %w", path, err)
+	}
+
+	return &Bolt{db: db, sealer: sealer}, nil
+}
+
+// checkSealer verifies sealer matches the key the database was written with,
+// recording it if the database is new.
+func checkSealer(tx *bolt.Tx, sealer *Sealer) error {
+	meta := tx.Bucket(metaBucket)
+	check := meta.Get(sealerCheckKey)
+
+	if check == nil {
+		if !isEmpty(tx.Bucket(sharesBucket)) || !isEmpty(tx.Bucket(tokenKeysBucket)) {
+			if sealer != nil {
+				return fmt.Errorf("database is not encrypted, rekey it to enable encryption")
+			}
+			return nil
+		}
+		if sealer == nil {
+			return nil
+		}
+		return meta.Put(sealerCheckKey, sealer.Seal(sealerCheck, sealerCheckKey))
+	}
+
+	if sealer == nil {
+		return fmt.Errorf("database is encrypted, a store key is required")
+	}
+	if _, err := sealer.Open(check, sealerCheckKey); err != nil {
+		return fmt.Errorf("store key does not match database: %w", err)
+	}
+	return nil
+}
+
+// Rekey re-encrypts every share and token key under next in a single transaction and
+// switches the store to it. Passing a nil current sealer encrypts a
+// plaintext database; passing a nil next decrypts it.
+func (b *Bolt) Rekey(next *Sealer) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		shares := tx.Bucket(sharesBucket)
+
+		var recipients [][]byte
+		err := shares.ForEachBucket(func(recipient []byte) error {
+			recipients = append(recipients, append([]byte{}, recipient...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, recipient := range recipients {
+			var records []Share
+			err := shares.Bucket(recipient).ForEach(func(key, value []byte) error {
+				share, err := openShare(b.sealer, recipient, key, value)
+				if err != nil {
+					return err
+				}
+				records = append(records, *share)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := shares.DeleteBucket(recipient); err != nil {
+				return err
+			}
+			bucket, err := shares.CreateBucket(recipient)
+			if err != nil {
+				return err
+			}
+			for _, share := range records {
+				key, value, err := sealShare(next, recipient, share)
+				if err != nil {
+					return err
+				}
+				if err := bucket.Put(key, value); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := b.rekeyTokenKeys(tx, next); err != nil {
+			return err
+		}
+
+		meta := tx.Bucket(metaBucket)
+		if next == nil {
+			return meta.Delete(sealerCheckKey)
+		}
+		return meta.Put(sealerCheckKey, next.Seal(sealerCheck, sealerCheckKey))
+	})
+	if err != nil {
+		return err
+	}
+
+	b.sealer = next
+	return nil
+}
+
+func (b *Bolt) rekeyTokenKeys(tx *bolt.Tx, next *Sealer) error {
+	tokenKeys := tx.Bucket(tokenKeysBucket)
+
+	var epochs [][]byte
+	err := tokenKeys.ForEachBucket(func(epoch []byte) error {
+		epochs = append(epochs, append([]byte{}, epoch...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, epoch := range epochs {
+		var records []tokenKeyRecord
+		err := tokenKeys.Bucket(epoch).ForEach(func(key, value []byte) error {
+			record, err := openTokenKey(b.sealer, epoch, key, value)
+			if err != nil {
+				return err
+			}
+			records = append(records, *record)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tokenKeys.DeleteBucket(epoch); err != nil {
+			return err
+		}
+		bucket, err := tokenKeys.CreateBucket(epoch)
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			key, value, err := sealTokenKey(next, epoch, record)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+func (b *Bolt) PutRecipient(r types.Recipient) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(recipientsBucket).Put([]byte(r.PublicKey), value)
+	})
+}
+
+func (b *Bolt) Recipient(publicKey string) (*types.Recipient, error) {
+	var r types.Recipient
+	err := b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(recipientsBucket).Get([]byte(publicKey))
+		if value == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(value, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (b *Bolt) Recipients() ([]types.Recipient, error) {
+	var result []types.Recipient
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(recipientsBucket).ForEach(func(_, value []byte) error {
+			var r types.Recipient
+			if err := json.Unmarshal(value, &r); err != nil {
+				return err
+			}
+			result = append(result, r)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (b *Bolt) DeleteRecipient(publicKey string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		recipients := tx.Bucket(recipientsBucket)
+		if recipients.Get([]byte(publicKey)) == nil {
+			return ErrNotFound
+		}
+		return recipients.Delete([]byte(publicKey))
+	})
+}
+
+func (b *Bolt) PutChallenge(publicKey string, nonce string, challenge types.Challenge, limit int, cutoff time.Time) error {
+	value, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(challengesBucket).CreateBucketIfNotExists([]byte(publicKey))
+		if err != nil {
+			return err
+		}
+
+		expired, err := expiredChallenges(bucket, cutoff)
+		if err != nil {
+			return err
+		}
+		for _, nonce := range expired {
+			if err := bucket.Delete(nonce); err != nil {
+				return err
+			}
+		}
+
+		// Stats only counts committed keys, so count the deletions above.
+		if countKeys(bucket) >= limit {
+			return ErrLimit
+		}
+		return bucket.Put([]byte(nonce), value)
+	})
+}
+
+func (b *Bolt) TakeChallenge(publicKey string, nonce string) (*types.Challenge, error) {
+	var challenge types.Challenge
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		challenges := tx.Bucket(challengesBucket)
+		bucket := challenges.Bucket([]byte(publicKey))
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		value := bucket.Get([]byte(nonce))
+		if value == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(value, &challenge); err != nil {
+			return err
+		}
+
+		if err := bucket.Delete([]byte(nonce)); err != nil {
+			return err
+		}
+		if isEmpty(bucket) {
+			return challenges.DeleteBucket([]byte(publicKey))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (b *Bolt) PurgeChallenges(cutoff time.Time) (int, error) {
+	purged := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		challenges := tx.Bucket(challengesBucket)
+
+		var publicKeys [][]byte
+		err := challenges.ForEachBucket(func(publicKey []byte) error {
+			publicKeys = append(publicKeys, append([]byte{}, publicKey...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, publicKey := range publicKeys {
+			bucket := challenges.Bucket(publicKey)
+			expired, err := expiredChallenges(bucket, cutoff)
+			if err != nil {
+				return err
+			}
+
+			for _, nonce := range expired {
+				if err := bucket.Delete(nonce); err != nil {
+					return err
+				}
+			}
+			purged += len(expired)
+			if isEmpty(bucket) {
+				if err := challenges.DeleteBucket(publicKey); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+func (b *Bolt) PutTokenKey(org string, epoch int64, key []byte, limit int) error {
+	epochKey := binary.BigEndian.AppendUint64(nil, uint64(epoch))
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(tokenKeysBucket).CreateBucketIfNotExists(epochKey)
+		if err != nil {
+			return err
+		}
+
+		index, value, err := sealTokenKey(b.sealer, epochKey, tokenKeyRecord{Org: org, Key: key})
+		if err != nil {
+			return err
+		}
+		if bucket.Get(index) == nil && bucket.Stats().KeyN >= limit {
+			return ErrLimit
+		}
+		return bucket.Put(index, value)
+	})
+}
+
+func (b *Bolt) TokenKey(org string, epoch int64) ([]byte, error) {
+	epochKey := binary.BigEndian.AppendUint64(nil, uint64(epoch))
+	var key []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(tokenKeysBucket).Bucket(epochKey)
+		if bucket == nil {
+			return ErrNotFound
+		}
+		index := b.sealer.Index([]byte(org))
+		value := bucket.Get(index)
+		if value == nil {
+			return ErrNotFound
+		}
+
+		record, err := openTokenKey(b.sealer, epochKey, index, bytes.Clone(value))
+		if err != nil {
+			return err
+		}
+		if record.Org != org {
+			return fmt.Errorf("token key for %q is sealed as another organization's", org)
+		}
+		key = record.Key
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (b *Bolt) PurgeTokenKeys(before int64) (int, error) {
+	purged := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		tokenKeys := tx.Bucket(tokenKeysBucket)
+
+		var expired [][]byte
+		err := tokenKeys.ForEachBucket(func(epoch []byte) error {
+			if int64(binary.BigEndian.Uint64(epoch)) < before {
+				expired = append(expired, append([]byte{}, epoch...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, epoch := range expired {
+			purged += tokenKeys.Bucket(epoch).Stats().KeyN
+			if err := tokenKeys.DeleteBucket(epoch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// tokenKeyRecord is a token key as sealed at rest. The organization is kept
+// inside it, so Rekey can index it under the next sealer.
+type tokenKeyRecord struct {
+	Org string `json:"org"`
+	Key []byte `json:"key"`
+}
+
+func sealTokenKey(sealer *Sealer, epoch []byte, record tokenKeyRecord) ([]byte, []byte, error) {
+	plaintext, err := json.Marshal(record)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := sealer.Index([]byte(record.Org))
+	return key, sealer.Seal(plaintext, tokenKeyAdditionalData(epoch, key)), nil
+}
+
+func openTokenKey(sealer *Sealer, epoch []byte, key []byte, value []byte) (*tokenKeyRecord, error) {
+	plaintext, err := sealer.Open(value, tokenKeyAdditionalData(epoch, key))
+	if err != nil {
+		return nil, err
+	}
+
+	var record tokenKeyRecord
+	if err := json.Unmarshal(plaintext, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// tokenKeyAdditionalData binds a sealed token key to its epoch and to the
+// index of its organization.
+func tokenKeyAdditionalData(epoch []byte, key []byte) []byte {
+	return append(append([]byte{}, epoch...), key...)
+}
+
+// expiredChallenges returns the nonces of the challenges in bucket issued
+// before cutoff.
+func expiredChallenges(bucket *bolt.Bucket, cutoff time.Time) ([][]byte, error) {
+	var expired [][]byte
+	err := bucket.ForEach(func(nonce, value []byte) error {
+		var challenge types.Challenge
+		if err := json.Unmarshal(value, &challenge); err != nil {
+			return err
+		}
+		if challenge.IssuedAt.Before(cutoff) {
+			expired = append(expired, append([]byte{}, nonce...))
+		}
+		return nil
+	})
+	return expired, err
+}
+
+func (b *Bolt) PutShare(recipient []byte, share Share) error {
+	key, value, err := sealShare(b.sealer, recipient, share)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(sharesBucket).CreateBucketIfNotExists(recipient)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, value)
+	})
+}
+
+func (b *Bolt) Shares(recipient []byte) ([]Share, error) {
+	var result []Share
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sharesBucket).Bucket(recipient)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, value []byte) error {
+			share, err := openShare(b.sealer, recipient, key, value)
+			if err != nil {
+				return err
+			}
+			result = append(result, *share)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (b *Bolt) DeleteShare(recipient []byte, id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		shares := tx.Bucket(sharesBucket)
+		bucket := shares.Bucket(recipient)
+		if bucket == nil {
+			return nil
+		}
+
+		if err := bucket.Delete(b.sealer.Index([]byte(id))); err != nil {
+			return err
+		}
+		if isEmpty(bucket) {
+			return shares.DeleteBucket(recipient)
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) SpendCredential(scope []byte, id string, expires time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(credentialsBucket).CreateBucketIfNotExists(scope)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().Unix()
+		var expired [][]byte
+		err = bucket.ForEach(func(key, value []byte) error {
+			if int64(binary.BigEndian.Uint64(value)) <= now {
+				expired = append(expired, append([]byte{}, key...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		if bucket.Get([]byte(id)) != nil {
+			return ErrSpent
+		}
+		return bucket.Put([]byte(id), binary.BigEndian.AppendUint64(nil, uint64(expires.Unix())))
+	})
+}
+
+func (b *Bolt) DeleteShares(recipient []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return deleteBucketIfExists(tx.Bucket(sharesBucket), recipient)
+	})
+}
+
+// Readdress re-seals each share for its new recipient, since sealed shares
+// are bound to the recipient they are stored under.
+func (b *Bolt) Readdress(from []byte, to []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		shares := tx.Bucket(sharesBucket)
+		if bucket := shares.Bucket(from); bucket != nil {
+			target, err := shares.CreateBucketIfNotExists(to)
+			if err != nil {
+				return err
+			}
+			err = bucket.ForEach(func(key, value []byte) error {
+				share, err := openShare(b.sealer, from, key, value)
+				if err != nil {
+					return err
+				}
+				sealedKey, sealedValue, err := sealShare(b.sealer, to, *share)
+				if err != nil {
+					return err
+				}
+				return target.Put(sealedKey, sealedValue)
+			})
+			if err != nil {
+				return err
+			}
+			if err := shares.DeleteBucket(from); err != nil {
+				return err
+			}
+		}
+
+		credentials := tx.Bucket(credentialsBucket)
+		if bucket := credentials.Bucket(from); bucket != nil {
+			target, err := credentials.CreateBucketIfNotExists(to)
+			if err != nil {
+				return err
+			}
+			err = bucket.ForEach(func(id, expiry []byte) error {
+				return target.Put(id, expiry)
+			})
+			if err != nil {
+				return err
+			}
+			if err := credentials.DeleteBucket(from); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Bolt) PurgeShares(cutoff time.Time) (int, error) {
+	purged := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		shares := tx.Bucket(sharesBucket)
+
+		var recipients [][]byte
+		err := shares.ForEachBucket(func(recipient []byte) error {
+			recipients = append(recipients, append([]byte{}, recipient...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, recipient := range recipients {
+			bucket := shares.Bucket(recipient)
+			var expired [][]byte
+			err := bucket.ForEach(func(key, value []byte) error {
+				share, err := openShare(b.sealer, recipient, key, value)
+				if err != nil {
+					return err
+				}
+				if share.SubmittedAt.Before(cutoff) {
+					expired = append(expired, append([]byte{}, key...))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, key := range expired {
+				if err := bucket.Delete(key); err != nil {
+					return err
+				}
+			}
+			purged += len(expired)
+			if isEmpty(bucket) {
+				if err := shares.DeleteBucket(recipient); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// sealShare returns the key and value a share is stored under. The sealed
+// value is bound to its recipient and key so records cannot be swapped.
+func sealShare(sealer *Sealer, recipient []byte, share Share) ([]byte, []byte, error) {
+	plaintext, err := json.Marshal(share)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := sealer.Index([]byte(share.ID))
+	return key, sealer.Seal(plaintext, shareAdditionalData(recipient, key)), nil
+}
+
+func openShare(sealer *Sealer, recipient []byte, key []byte, value []byte) (*Share, error) {
+	plaintext, err := sealer.Open(value, shareAdditionalData(recipient, key))
+	if err != nil {
+		return nil, err
+	}
+
+	var share Share
+	if err := json.Unmarshal(plaintext, &share); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func shareAdditionalData(recipient []byte, key []byte) []byte {
+	return append(append([]byte{}, recipient...), key...)
+}
+
+func deleteBucketIfExists(parent *bolt.Bucket, name []byte) error {
+	if parent.Bucket(name) == nil {
+		return nil
+	}
+	return parent.DeleteBucket(name)
+}
+
+func countKeys(bucket *bolt.Bucket) int {
+	n := 0
+	c := bucket.Cursor()
+	for key, _ := c.First(); key != nil; key, _ = c.Next() {
+		n++
+	}
+	return n
+}
+
+func isEmpty(bucket *bolt.Bucket) bool {
+	key, _ := bucket.Cursor().First()
+	return key == nil
+}
+
+func (b *Bolt) AdvanceDirectory(hash []byte) (uint64, error) {
+	var epoch uint64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if value := meta.Get(epochKey); value != nil {
+			epoch = binary.BigEndian.Uint64(value)
+			if bytes.Equal(hash, meta.Get(hashKey)) {
+				return nil
+			}
+		}
+
+		epoch++
+		if err := meta.Put(epochKey, binary.BigEndian.AppendUint64(nil, epoch)); err != nil {
+			return err
+		}
+		return meta.Put(hashKey, hash)
+	})
+	return epoch, err
+}
+
+func (b *Bolt) AppendLog(entry []byte) (uint64, error) {
+	var index uint64
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		log := tx.Bucket(logBucket)
+		if last, _ := log.Cursor().Last(); last != nil {
+			index = binary.BigEndian.Uint64(last) + 1
+		}
+		return log.Put(binary.BigEndian.AppendUint64(nil, index), entry)
+	})
+	return index, err
+}
+
+func (b *Bolt) LogEntries(start uint64, end uint64) ([][]byte, error) {
+	var entries [][]byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(logBucket).Cursor()
+		for k, v := c.Seek(binary.BigEndian.AppendUint64(nil, start)); k != nil && binary.BigEndian.Uint64(k) < end; k, v = c.Next() {
+			entries = append(entries, bytes.Clone(v))
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (b *Bolt) LogSize() (uint64, error) {
+	var size uint64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if last, _ := tx.Bucket(logBucket).Cursor().Last(); last != nil {
+			size = binary.BigEndian.Uint64(last) + 1
+		}
+		return nil
+	})
+	return size, err
+}
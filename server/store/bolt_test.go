@@ -0,0 +1,221 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestBolt(t *testing.T, path string) *Bolt {
+	b, err := OpenBolt(path, nil)
+	require.NoError(t, err)
+	return b
+}
+
+func newTestSealer(t *testing.T) *Sealer {
+	key, err := ParseKey(GenerateKey())
+	require.NoError(t, err)
+	sealer, err := NewSealer(key)
+	require.NoError(t, err)
+	return sealer
+}
+
+func TestBolt(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		b := openTestBolt(t, filepath.Join(t.TempDir(), "rendezvous.db"))
+		t.Cleanup(func() { b.Close() })
+		return b
+	})
+}
+
+func TestBolt_Sealed(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		b, err := OpenBolt(filepath.Join(t.TempDir(), "rendezvous.db"), newTestSealer(t))
+		require.NoError(t, err)
+		t.Cleanup(func() { b.Close() })
+		return b
+	})
+}
+
+func TestBolt_SealedAtRest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rendezvous.db")
+	sealer := newTestSealer(t)
+
+	b, err := OpenBolt(path, sealer)
+	require.NoError(t, err)
+	assert.NoError(t, b.PutShare([]byte("recipient"), Share{
+		ID:              "disclosure-id-secret",
+		Org:             "Org Name Secret",
+		VerifiableShare: types.VerifiableShare{Data: "share-data-secret"},
+	}))
+	assert.NoError(t, b.PutTokenKey("OrgA", 1, []byte("token-key-secret"), 1))
+	assert.NoError(t, b.Close())
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"disclosure-id-secret", "Org Name Secret", "share-data-secret", "OrgA", "token-key-secret"} {
+		assert.False(t, bytes.Contains(raw, []byte(secret)), "%q stored in plaintext", secret)
+	}
+
+	_, err = OpenBolt(path, nil)
+	assert.ErrorContains(t, err, "store key is required")
+
+	_, err = OpenBolt(path, newTestSealer(t))
+	assert.ErrorIs(t, err, ErrWrongKey)
+
+	b, err = OpenBolt(path, sealer)
+	require.NoError(t, err)
+	defer b.Close()
+
+	shares, err := b.Shares([]byte("recipient"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Org Name Secret", shares[0].Org)
+}
+
+func TestBolt_Rekey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rendezvous.db")
+	recipient := []byte("recipient")
+	first, second := newTestSealer(t), newTestSealer(t)
+
+	b := openTestBolt(t, path)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, b.PutShare(recipient, Share{ID: fmt.Sprintf("id-%d", i), Org: "OrgA"}))
+	}
+	assert.NoError(t, b.Close())
+
+	// A plaintext database must be explicitly rekeyed to enable encryption.
+	_, err := OpenBolt(path, first)
+	assert.ErrorContains(t, err, "not encrypted")
+
+	b = openTestBolt(t, path)
+	assert.NoError(t, b.PutTokenKey("OrgA", 1, []byte("token-key"), 1))
+	assert.NoError(t, b.Rekey(first))
+	assert.NoError(t, b.Rekey(second))
+	assert.NoError(t, b.DeleteShare(recipient, "id-0"))
+	assert.NoError(t, b.Close())
+
+	_, err = OpenBolt(path, first)
+	assert.ErrorIs(t, err, ErrWrongKey)
+
+	b, err = OpenBolt(path, second)
+	require.NoError(t, err)
+	defer b.Close()
+
+	shares, err := b.Shares(recipient)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []Share{{ID: "id-1", Org: "OrgA"}, {ID: "id-2", Org: "OrgA"}}, shares)
+	key, err := b.TokenKey("OrgA", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("token-key"), key)
+}
+
+func TestBolt_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rendezvous.db")
+	recipient := []byte("recipient")
+
+	b := openTestBolt(t, path)
+	assert.NoError(t, b.PutRecipient(types.Recipient{Name: "Alice", PublicKey: "key-a"}))
+	assert.NoError(t, b.PutChallenge("key-a", "nonce", types.Challenge{Token: []byte("token")}, 1, time.Time{}))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, b.PutShare(recipient, Share{ID: fmt.Sprintf("id-%d", i), Org: "OrgA"}))
+	}
+	_, err := b.AdvanceDirectory([]byte("hash-1"))
+	assert.NoError(t, err)
+	_, err = b.AdvanceDirectory([]byte("hash-2"))
+	assert.NoError(t, err)
+	assert.NoError(t, b.Close())
+
+	b = openTestBolt(t, path)
+	defer b.Close()
+
+	recipients, err := b.Recipients()
+	assert.NoError(t, err)
+	assert.Equal(t, []types.Recipient{{Name: "Alice", PublicKey: "key-a"}}, recipients)
+
+	challenge, err := b.TakeChallenge("key-a", "nonce")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("token"), challenge.Token)
+
+	shares, err := b.Shares(recipient)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 2)
+
+	epoch, err := b.AdvanceDirectory([]byte("hash-2"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), epoch)
+}
+
+func TestBolt_ReopenMidWorkload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rendezvous.db")
+	recipient := []byte("recipient")
+
+	b := openTestBolt(t, path)
+
+	// Writers race against Close; every write that reported success must
+	// survive the reopen and no partial record may appear.
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		committed []string
+		started   = make(chan struct{})
+	)
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				if i == 10 && w == 0 {
+					close(started)
+				}
+				id := fmt.Sprintf("id-%d-%d", w, i)
+				err := b.PutShare(recipient, Share{ID: id, Org: "OrgA", VerifiableShare: types.VerifiableShare{Data: id}})
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				committed = append(committed, id)
+				mu.Unlock()
+			}
+		}(w)
+	}
+
+	<-started
+	assert.NoError(t, b.Close())
+	wg.Wait()
+
+	b = openTestBolt(t, path)
+	defer b.Close()
+
+	shares, err := b.Shares(recipient)
+	assert.NoError(t, err)
+
+	stored := map[string]bool{}
+	for _, share := range shares {
+		assert.Equal(t, share.ID, share.VerifiableShare.Data)
+		stored[share.ID] = true
+	}
+	for _, id := range committed {
+		assert.True(t, stored[id], "committed share %s lost", id)
+	}
+
+	// The reopened store keeps accepting writes.
+	assert.NoError(t, b.PutShare(recipient, Share{ID: "after", Org: "OrgA"}))
+}
+
+func TestBolt_Locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rendezvous.db")
+
+	b := openTestBolt(t, path)
+	defer b.Close()
+
+	_, err := OpenBolt(path, nil)
+	assert.Error(t, err)
+}
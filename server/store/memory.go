@@ -0,0 +1,331 @@
+package store
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+)
+
+// Memory is a Store that keeps all state in process memory. Everything is
+// lost when the process exits.
+type Memory struct {
+	recipientsMu  sync.RWMutex
+	recipients    map[string]types.Recipient // publicKeyBase64 -> Recipient
+	challengesMu  sync.Mutex
+	challenges    map[string]map[string]types.Challenge // publicKeyBase64 -> nonce -> Challenge
+	sharesMu      sync.RWMutex
+	shares        map[string]map[string]Share // publicKey -> disclosureID -> Share
+	credentialsMu sync.Mutex
+	credentials   map[string]map[string]time.Time // scope -> credential ID -> expiry
+	tokenKeysMu   sync.Mutex
+	tokenKeys     map[int64]map[string][]byte // epoch -> org -> key
+	directoryMu   sync.Mutex
+	directoryHash []byte
+	epoch         uint64
+	logMu         sync.RWMutex
+	log           [][]byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		recipients:  map[string]types.Recipient{},
+		challenges:  map[string]map[string]types.Challenge{},
+		shares:      map[string]map[string]Share{},
+		credentials: map[string]map[string]time.Time{},
+		tokenKeys:   map[int64]map[string][]byte{},
+	}
+}
+
+func (m *Memory) PutRecipient(r types.Recipient) error {
+	m.recipientsMu.Lock()
+	defer m.recipientsMu.Unlock()
+
+	m.recipients[r.PublicKey] = r
+	return nil
+}
+
+func (m *Memory) Recipient(publicKey string) (*types.Recipient, error) {
+	m.recipientsMu.RLock()
+	defer m.recipientsMu.RUnlock()
+
+	r, ok := m.recipients[publicKey]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+func (m *Memory) Recipients() ([]types.Recipient, error) {
+	m.recipientsMu.RLock()
+	defer m.recipientsMu.RUnlock()
+
+	var result []types.Recipient
+	for _, r := range m.recipients {
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func (m *Memory) DeleteRecipient(publicKey string) error {
+	m.recipientsMu.Lock()
+	defer m.recipientsMu.Unlock()
+
+	if _, ok := m.recipients[publicKey]; !ok {
+		return ErrNotFound
+	}
+	delete(m.recipients, publicKey)
+	return nil
+}
+
+func (m *Memory) PutChallenge(publicKey string, nonce string, challenge types.Challenge, limit int, cutoff time.Time) error {
+	m.challengesMu.Lock()
+	defer m.challengesMu.Unlock()
+
+	if m.challenges[publicKey] == nil {
+		m.challenges[publicKey] = make(map[string]types.Challenge)
+	}
+	for existing, c := range m.challenges[publicKey] {
+		if c.IssuedAt.Before(cutoff) {
+			delete(m.challenges[publicKey], existing)
+		}
+	}
+	if len(m.challenges[publicKey]) >= limit {
+		return ErrLimit
+	}
+	m.challenges[publicKey][nonce] = challenge
+	return nil
+}
+
+func (m *Memory) TakeChallenge(publicKey string, nonce string) (*types.Challenge, error) {
+	m.challengesMu.Lock()
+	defer m.challengesMu.Unlock()
+
+	recipientChallenges, ok := m.challenges[publicKey]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	challenge, ok := recipientChallenges[nonce]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	delete(recipientChallenges, nonce)
+	if len(recipientChallenges) == 0 {
+		delete(m.challenges, publicKey)
+	}
+	return &challenge, nil
+}
+
+func (m *Memory) PurgeChallenges(cutoff time.Time) (int, error) {
+	m.challengesMu.Lock()
+	defer m.challengesMu.Unlock()
+
+	purged := 0
+	for publicKey, challenges := range m.challenges {
+		for nonce, challenge := range challenges {
+			if challenge.IssuedAt.Before(cutoff) {
+				delete(challenges, nonce)
+				purged++
+			}
+		}
+		if len(challenges) == 0 {
+			delete(m.challenges, publicKey)
+		}
+	}
+	return purged, nil
+}
+
+func (m *Memory) PutTokenKey(org string, epoch int64, key []byte, limit int) error {
+	m.tokenKeysMu.Lock()
+	defer m.tokenKeysMu.Unlock()
+
+	if m.tokenKeys[epoch] == nil {
+		m.tokenKeys[epoch] = make(map[string][]byte)
+	}
+	keys := m.tokenKeys[epoch]
+	if _, ok := keys[org]; !ok && len(keys) >= limit {
+		return ErrLimit
+	}
+	keys[org] = bytes.Clone(key)
+	return nil
+}
+
+func (m *Memory) TokenKey(org string, epoch int64) ([]byte, error) {
+	m.tokenKeysMu.Lock()
+	defer m.tokenKeysMu.Unlock()
+
+	key, ok := m.tokenKeys[epoch][org]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(key), nil
+}
+
+func (m *Memory) PurgeTokenKeys(before int64) (int, error) {
+	m.tokenKeysMu.Lock()
+	defer m.tokenKeysMu.Unlock()
+
+	purged := 0
+	for epoch, keys := range m.tokenKeys {
+		if epoch < before {
+			purged += len(keys)
+			delete(m.tokenKeys, epoch)
+		}
+	}
+	return purged, nil
+}
+
+func (m *Memory) PutShare(recipient []byte, share Share) error {
+	m.sharesMu.Lock()
+	defer m.sharesMu.Unlock()
+
+	key := string(recipient)
+	if m.shares[key] == nil {
+		m.shares[key] = make(map[string]Share)
+	}
+	m.shares[key][share.ID] = share
+	return nil
+}
+
+func (m *Memory) Shares(recipient []byte) ([]Share, error) {
+	m.sharesMu.RLock()
+	defer m.sharesMu.RUnlock()
+
+	var result []Share
+	for _, share := range m.shares[string(recipient)] {
+		result = append(result, share)
+	}
+	return result, nil
+}
+
+func (m *Memory) DeleteShare(recipient []byte, id string) error {
+	m.sharesMu.Lock()
+	defer m.sharesMu.Unlock()
+
+	key := string(recipient)
+	delete(m.shares[key], id)
+	if len(m.shares[key]) == 0 {
+		delete(m.shares, key)
+	}
+	return nil
+}
+
+func (m *Memory) DeleteShares(recipient []byte) error {
+	m.sharesMu.Lock()
+	defer m.sharesMu.Unlock()
+
+	delete(m.shares, string(recipient))
+	return nil
+}
+
+func (m *Memory) Readdress(from []byte, to []byte) error {
+	m.sharesMu.Lock()
+	defer m.sharesMu.Unlock()
+	m.credentialsMu.Lock()
+	defer m.credentialsMu.Unlock()
+
+	if shares, ok := m.shares[string(from)]; ok {
+		if m.shares[string(to)] == nil {
+			m.shares[string(to)] = make(map[string]Share)
+		}
+		for id, share := range shares {
+			m.shares[string(to)][id] = share
+		}
+		delete(m.shares, string(from))
+	}
+
+	if spent, ok := m.credentials[string(from)]; ok {
+		if m.credentials[string(to)] == nil {
+			m.credentials[string(to)] = make(map[string]time.Time)
+		}
+		for id, expiry := range spent {
+			m.credentials[string(to)][id] = expiry
+		}
+		delete(m.credentials, string(from))
+	}
+	return nil
+}
+
+func (m *Memory) PurgeShares(cutoff time.Time) (int, error) {
+	m.sharesMu.Lock()
+	defer m.sharesMu.Unlock()
+
+	purged := 0
+	for key, shares := range m.shares {
+		for id, share := range shares {
+			if share.SubmittedAt.Before(cutoff) {
+				delete(shares, id)
+				purged++
+			}
+		}
+		if len(shares) == 0 {
+			delete(m.shares, key)
+		}
+	}
+	return purged, nil
+}
+
+func (m *Memory) SpendCredential(scope []byte, id string, expires time.Time) error {
+	m.credentialsMu.Lock()
+	defer m.credentialsMu.Unlock()
+
+	key := string(scope)
+	spent := m.credentials[key]
+	if spent == nil {
+		spent = make(map[string]time.Time)
+		m.credentials[key] = spent
+	}
+
+	now := time.Now()
+	for spentID, expiry := range spent {
+		if !now.Before(expiry) {
+			delete(spent, spentID)
+		}
+	}
+
+	if _, ok := spent[id]; ok {
+		return ErrSpent
+	}
+	spent[id] = expires
+	return nil
+}
+
+func (m *Memory) AdvanceDirectory(hash []byte) (uint64, error) {
+	m.directoryMu.Lock()
+	defer m.directoryMu.Unlock()
+
+	if m.epoch == 0 || !bytes.Equal(hash, m.directoryHash) {
+		m.epoch++
+		m.directoryHash = bytes.Clone(hash)
+	}
+	return m.epoch, nil
+}
+
+func (m *Memory) AppendLog(entry []byte) (uint64, error) {
+	m.logMu.Lock()
+	defer m.logMu.Unlock()
+
+	m.log = append(m.log, bytes.Clone(entry))
+	return uint64(len(m.log) - 1), nil
+}
+
+func (m *Memory) LogEntries(start uint64, end uint64) ([][]byte, error) {
+	m.logMu.RLock()
+	defer m.logMu.RUnlock()
+
+	end = min(end, uint64(len(m.log)))
+	if start >= end {
+		return nil, nil
+	}
+	return slices.Clone(m.log[start:end]), nil
+}
+
+func (m *Memory) LogSize() (uint64, error) {
+	m.logMu.RLock()
+	defer m.logMu.RUnlock()
+
+	return uint64(len(m.log)), nil
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	cryptoRand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	KeySize   = 32
+	keyIDSize = 8
+)
+
+var (
+	ErrWrongKey = errors.New("record sealed under a different key")
+)
+
+// Sealer encrypts records at rest under an operator-held key. Values are
+// sealed with AES-GCM and lookup keys are replaced by an HMAC blind index,
+// so neither reveals its plaintext without the key.
+//
+// A nil *Sealer is valid and leaves data in plaintext.
+type Sealer struct {
+	id       []byte
+	aead     cipher.AEAD
+	indexKey []byte
+}
+
+// NewSealer derives the encryption and index keys from a 32 byte master key.
+func NewSealer(key []byte) (*Sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("store key must be %d bytes", KeySize)
+	}
+
+	derive := func(info string, size int) ([]byte, error) {
+		out := make([]byte, size)
+		if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(info)), out); err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	encryptionKey, err := derive("rendezvous-store-encryption", 32)
+	if err != nil {
+		return nil, err
+	}
+	indexKey, err := derive("rendezvous-store-index", 32)
+	if err != nil {
+		return nil, err
+	}
+	id, err := derive("rendezvous-store-key-id", keyIDSize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{id: id, aead: aead, indexKey: indexKey}, nil
+}
+
+// ParseKey decodes a base64 encoded store key.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid store key encoding: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("store key must be %d bytes", KeySize)
+	}
+	return key, nil
+}
+
+// ReadKeyFile reads a base64 encoded store key from path.
+func ReadKeyFile(path string) ([]byte, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKey(string(encoded))
+}
+
+// GenerateKey returns a new random base64 encoded store key.
+func GenerateKey() string {
+	key := make([]byte, KeySize)
+	cryptoRand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// Seal encrypts plaintext, binding it to additionalData.
+func (s *Sealer) Seal(plaintext []byte, additionalData []byte) []byte {
+	if s == nil {
+		return plaintext
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	cryptoRand.Read(nonce)
+
+	out := append([]byte{}, s.id...)
+	out = append(out, nonce...)
+	return s.aead.Seal(out, nonce, plaintext, additionalData)
+}
+
+// Open decrypts a value produced by Seal with the same additionalData.
+func (s *Sealer) Open(sealed []byte, additionalData []byte) ([]byte, error) {
+	if s == nil {
+		return sealed, nil
+	}
+
+	if len(sealed) < keyIDSize+s.aead.NonceSize() {
+		return nil, fmt.Errorf("sealed record too short")
+	}
+	if !bytes.Equal(sealed[:keyIDSize], s.id) {
+		return nil, ErrWrongKey
+	}
+
+	nonce := sealed[keyIDSize : keyIDSize+s.aead.NonceSize()]
+	plaintext, err := s.aead.Open(nil, nonce, sealed[keyIDSize+s.aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Index returns the lookup key used to store value.
+func (s *Sealer) Index(value []byte) []byte {
+	if s == nil {
+		return value
+	}
+
+	mac := hmac.New(sha256.New, s.indexKey)
+	mac.Write(value)
+	return mac.Sum(nil)
+}
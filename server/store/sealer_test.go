@@ -0,0 +1,54 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealer_RoundTrip(t *testing.T) {
+	sealer := newTestSealer(t)
+
+	sealed := sealer.Seal([]byte("plaintext"), []byte("aad"))
+	assert.NotContains(t, string(sealed), "plaintext")
+
+	opened, err := sealer.Open(sealed, []byte("aad"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("plaintext"), opened)
+
+	_, err = sealer.Open(sealed, []byte("other"))
+	assert.Error(t, err)
+
+	_, err = newTestSealer(t).Open(sealed, []byte("aad"))
+	assert.ErrorIs(t, err, ErrWrongKey)
+}
+
+func TestSealer_Index(t *testing.T) {
+	sealer := newTestSealer(t)
+
+	assert.Equal(t, sealer.Index([]byte("id")), sealer.Index([]byte("id")))
+	assert.NotEqual(t, sealer.Index([]byte("id")), sealer.Index([]byte("other")))
+	assert.NotEqual(t, sealer.Index([]byte("id")), newTestSealer(t).Index([]byte("id")))
+}
+
+func TestSealer_Nil(t *testing.T) {
+	var sealer *Sealer
+
+	assert.Equal(t, []byte("value"), sealer.Seal([]byte("value"), nil))
+	assert.Equal(t, []byte("value"), sealer.Index([]byte("value")))
+
+	opened, err := sealer.Open([]byte("value"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), opened)
+}
+
+func TestParseKey(t *testing.T) {
+	_, err := ParseKey(GenerateKey() + "\n")
+	assert.NoError(t, err)
+
+	_, err = ParseKey("!!!")
+	assert.Error(t, err)
+
+	_, err = ParseKey("c2hvcnQ=")
+	assert.ErrorContains(t, err, "32 bytes")
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrSpent    = errors.New("credential already spent")
+	ErrLimit    = errors.New("too many outstanding challenges")
+)
+
+// Share is a verifiable share held for a recipient until it is released.
+type Share struct {
+	ID              string                `json:"id"`
+	Org             string                `json:"org"`
+	VerifiableShare types.VerifiableShare `json:"verifiableShare"`
+	SubmittedAt     time.Time             `json:"submittedAt"`
+	// Protocol is the disclosure protocol version the share was submitted
+	// under.
+	Protocol int `json:"protocol,omitempty"`
+}
+
+// Store persists the state a rendezvous point needs between requests.
+//
+// Recipients are keyed by their base64 encoded public key, challenges by the
+// URL-safe public key used in inbox routes, and shares by the raw public key
+// bytes. Implementations must be safe for concurrent use.
+type Store interface {
+	PutRecipient(r types.Recipient) error
+	Recipient(publicKey string) (*types.Recipient, error)
+	Recipients() ([]types.Recipient, error)
+	// DeleteRecipient removes a registration, returning ErrNotFound if there
+	// is none.
+	DeleteRecipient(publicKey string) error
+
+	// PutChallenge stores a challenge, returning ErrLimit if publicKey
+	// already has limit outstanding. Challenges for publicKey issued before
+	// cutoff are deleted first, so expired ones never hold a slot.
+	PutChallenge(publicKey string, nonce string, challenge types.Challenge, limit int, cutoff time.Time) error
+	// TakeChallenge removes and returns the challenge, so that each nonce can
+	// only be answered once.
+	TakeChallenge(publicKey string, nonce string) (*types.Challenge, error)
+	// PurgeChallenges deletes every challenge issued before cutoff,
+	// returning how many were deleted.
+	PurgeChallenges(cutoff time.Time) (int, error)
+
+	PutShare(recipient []byte, share Share) error
+	Shares(recipient []byte) ([]Share, error)
+	DeleteShare(recipient []byte, id string) error
+	// DeleteShares deletes every share held for recipient.
+	DeleteShares(recipient []byte) error
+	// Readdress moves every share and spent credential record held for one
+	// recipient to another, so a credential spent on the old key stays spent
+	// on the new one.
+	Readdress(from []byte, to []byte) error
+	// PurgeShares deletes every share submitted before cutoff, returning how
+	// many were deleted.
+	PurgeShares(cutoff time.Time) (int, error)
+
+	// PutTokenKey stores the blind signing key for org in epoch, returning
+	// ErrLimit if limit other organizations already have a key for it.
+	PutTokenKey(org string, epoch int64, key []byte, limit int) error
+	// TokenKey returns the key stored for org in epoch.
+	TokenKey(org string, epoch int64) ([]byte, error)
+	// PurgeTokenKeys deletes the keys of every epoch before epoch, returning
+	// how many were deleted.
+	PurgeTokenKeys(before int64) (int, error)
+
+	// SpendCredential records that the credential with the given ID has
+	// been used within scope, usually the recipient disclosed to, returning
+	// ErrSpent if it already was. Records may be forgotten once the
+	// credential expires.
+	SpendCredential(scope []byte, id string, expires time.Time) error
+
+	// AdvanceDirectory returns the epoch of the published directory with
+	// the given hash, starting a new epoch if it differs from the last one
+	// recorded. Epochs start at 1 and never decrease.
+	AdvanceDirectory(hash []byte) (uint64, error)
+
+	// AppendLog adds an entry to the end of the transparency log, returning
+	// its index. Entries are never changed or removed.
+	AppendLog(entry []byte) (uint64, error)
+	// LogEntries returns the entries from start up to but not including
+	// end, or up to the end of the log if it is shorter.
+	LogEntries(start uint64, end uint64) ([][]byte, error)
+	// LogSize returns the number of entries in the log.
+	LogSize() (uint64, error)
+}
@@ -0,0 +1,275 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore exercises the behaviour every Store implementation must share.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Run("Recipients", func(t *testing.T) {
+		s := newStore(t)
+
+		recipients, err := s.Recipients()
+		assert.NoError(t, err)
+		assert.Empty(t, recipients)
+
+		assert.NoError(t, s.PutRecipient(types.Recipient{Name: "Alice", PublicKey: "key-a"}))
+		assert.NoError(t, s.PutRecipient(types.Recipient{Name: "Bob", PublicKey: "key-b"}))
+		assert.NoError(t, s.PutRecipient(types.Recipient{Name: "Alicia", PublicKey: "key-a", Threshold: 5}))
+
+		recipients, err = s.Recipients()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []types.Recipient{
+			{Name: "Alicia", PublicKey: "key-a", Threshold: 5},
+			{Name: "Bob", PublicKey: "key-b"},
+		}, recipients)
+
+		r, err := s.Recipient("key-a")
+		assert.NoError(t, err)
+		assert.Equal(t, &types.Recipient{Name: "Alicia", PublicKey: "key-a", Threshold: 5}, r)
+
+		_, err = s.Recipient("missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		assert.NoError(t, s.DeleteRecipient("key-a"))
+		assert.ErrorIs(t, s.DeleteRecipient("key-a"), ErrNotFound)
+		recipients, err = s.Recipients()
+		assert.NoError(t, err)
+		assert.Equal(t, []types.Recipient{{Name: "Bob", PublicKey: "key-b"}}, recipients)
+	})
+
+	t.Run("Challenges", func(t *testing.T) {
+		s := newStore(t)
+		challenge := types.Challenge{Token: []byte("token"), Nonce: []byte("nonce")}
+
+		_, err := s.TakeChallenge("key", "nonce")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		assert.NoError(t, s.PutChallenge("key", "nonce", challenge, 1, time.Time{}))
+		assert.ErrorIs(t, s.PutChallenge("key", "other", challenge, 1, time.Time{}), ErrLimit)
+		assert.NoError(t, s.PutChallenge("other-key", "nonce", challenge, 1, time.Time{}))
+
+		_, err = s.TakeChallenge("key", "other")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		taken, err := s.TakeChallenge("key", "nonce")
+		assert.NoError(t, err)
+		assert.Equal(t, challenge.Token, taken.Token)
+
+		_, err = s.TakeChallenge("key", "nonce")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// Taking a challenge frees its slot.
+		assert.NoError(t, s.PutChallenge("key", "other", challenge, 1, time.Time{}))
+
+		// So does expiring, without waiting for a purge.
+		now := time.Now()
+		assert.NoError(t, s.PutChallenge("expiring", "old", types.Challenge{IssuedAt: now.Add(-time.Hour)}, 1, time.Time{}))
+		assert.NoError(t, s.PutChallenge("expiring", "new", types.Challenge{IssuedAt: now}, 1, now.Add(-time.Minute)))
+		_, err = s.TakeChallenge("expiring", "old")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("PurgeChallenges", func(t *testing.T) {
+		s := newStore(t)
+		now := time.Now()
+
+		assert.NoError(t, s.PutChallenge("key", "old", types.Challenge{IssuedAt: now.Add(-time.Hour)}, 5, time.Time{}))
+		assert.NoError(t, s.PutChallenge("key", "new", types.Challenge{IssuedAt: now}, 5, time.Time{}))
+		assert.NoError(t, s.PutChallenge("other", "old", types.Challenge{IssuedAt: now.Add(-time.Hour)}, 5, time.Time{}))
+
+		purged, err := s.PurgeChallenges(now.Add(-time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, purged)
+
+		_, err = s.TakeChallenge("key", "old")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = s.TakeChallenge("key", "new")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Shares", func(t *testing.T) {
+		s := newStore(t)
+		recipient := []byte("recipient")
+		share := Share{ID: "id-1", Org: "OrgA", VerifiableShare: types.VerifiableShare{Data: "data-1"}, Protocol: 1}
+
+		assert.NoError(t, s.PutShare(recipient, share))
+		assert.NoError(t, s.PutShare(recipient, Share{ID: "id-2", Org: "OrgB", VerifiableShare: types.VerifiableShare{Data: "data-2"}}))
+		assert.NoError(t, s.PutShare([]byte("other"), Share{ID: "id-3", Org: "OrgA"}))
+
+		shares, err := s.Shares(recipient)
+		assert.NoError(t, err)
+		assert.Len(t, shares, 2)
+		assert.Contains(t, shares, share)
+
+		assert.NoError(t, s.DeleteShare(recipient, "id-2"))
+		assert.NoError(t, s.DeleteShare(recipient, "missing"))
+
+		shares, err = s.Shares(recipient)
+		assert.NoError(t, err)
+		assert.Equal(t, []Share{share}, shares)
+
+		shares, err = s.Shares([]byte("other"))
+		assert.NoError(t, err)
+		assert.Len(t, shares, 1)
+	})
+
+	t.Run("Readdress", func(t *testing.T) {
+		s := newStore(t)
+		from, to := []byte("old-key"), []byte("new-key")
+		expires := time.Now().Add(time.Hour)
+
+		assert.NoError(t, s.PutShare(from, Share{ID: "id-1", Org: "OrgA"}))
+		assert.NoError(t, s.PutShare(from, Share{ID: "id-2", Org: "OrgB"}))
+		assert.NoError(t, s.PutShare(to, Share{ID: "id-3", Org: "OrgA"}))
+		assert.NoError(t, s.SpendCredential(from, "jti-1", expires))
+
+		assert.NoError(t, s.Readdress(from, to))
+		assert.NoError(t, s.Readdress([]byte("missing"), to))
+
+		shares, err := s.Shares(from)
+		assert.NoError(t, err)
+		assert.Empty(t, shares)
+		shares, err = s.Shares(to)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []Share{{ID: "id-1", Org: "OrgA"}, {ID: "id-2", Org: "OrgB"}, {ID: "id-3", Org: "OrgA"}}, shares)
+
+		assert.ErrorIs(t, s.SpendCredential(to, "jti-1", expires), ErrSpent)
+		assert.NoError(t, s.SpendCredential(from, "jti-1", expires))
+
+		assert.NoError(t, s.DeleteShares(to))
+		assert.NoError(t, s.DeleteShares(to))
+		shares, err = s.Shares(to)
+		assert.NoError(t, err)
+		assert.Empty(t, shares)
+	})
+
+	t.Run("PurgeShares", func(t *testing.T) {
+		s := newStore(t)
+		now := time.Now()
+		recipient := []byte("recipient")
+
+		assert.NoError(t, s.PutShare(recipient, Share{ID: "old", Org: "OrgA", SubmittedAt: now.Add(-2 * time.Hour)}))
+		assert.NoError(t, s.PutShare(recipient, Share{ID: "new", Org: "OrgA", SubmittedAt: now}))
+		assert.NoError(t, s.PutShare([]byte("other"), Share{ID: "old", Org: "OrgB", SubmittedAt: now.Add(-3 * time.Hour)}))
+
+		purged, err := s.PurgeShares(now.Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, purged)
+
+		shares, err := s.Shares(recipient)
+		assert.NoError(t, err)
+		assert.Len(t, shares, 1)
+		assert.Equal(t, "new", shares[0].ID)
+		assert.True(t, now.Equal(shares[0].SubmittedAt))
+
+		shares, err = s.Shares([]byte("other"))
+		assert.NoError(t, err)
+		assert.Empty(t, shares)
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		s := newStore(t)
+		recipient := []byte("recipient")
+		expires := time.Now().Add(time.Hour)
+
+		assert.NoError(t, s.SpendCredential(recipient, "jti-1", expires))
+		assert.ErrorIs(t, s.SpendCredential(recipient, "jti-1", expires), ErrSpent)
+		assert.NoError(t, s.SpendCredential([]byte("other"), "jti-1", expires))
+		assert.NoError(t, s.SpendCredential(recipient, "jti-2", expires))
+
+		// Expired records are forgotten.
+		assert.NoError(t, s.SpendCredential(recipient, "jti-3", time.Now().Add(-time.Second)))
+		assert.NoError(t, s.SpendCredential(recipient, "jti-3", expires))
+	})
+
+	t.Run("TokenKeys", func(t *testing.T) {
+		s := newStore(t)
+
+		_, err := s.TokenKey("OrgA", 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		assert.NoError(t, s.PutTokenKey("OrgA", 1, []byte("key-a1"), 2))
+		assert.NoError(t, s.PutTokenKey("OrgB", 1, []byte("key-b1"), 2))
+		assert.ErrorIs(t, s.PutTokenKey("OrgC", 1, []byte("key-c1"), 2), ErrLimit)
+		// Replacing a key does not count against the limit.
+		assert.NoError(t, s.PutTokenKey("OrgA", 1, []byte("key-a1"), 2))
+		assert.NoError(t, s.PutTokenKey("OrgC", 2, []byte("key-c2"), 2))
+
+		key, err := s.TokenKey("OrgA", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("key-a1"), key)
+		_, err = s.TokenKey("OrgA", 2)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		purged, err := s.PurgeTokenKeys(2)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, purged)
+		_, err = s.TokenKey("OrgA", 1)
+		assert.ErrorIs(t, err, ErrNotFound)
+		key, err = s.TokenKey("OrgC", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("key-c2"), key)
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		s := newStore(t)
+
+		epoch, err := s.AdvanceDirectory([]byte("hash-1"))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), epoch)
+
+		epoch, err = s.AdvanceDirectory([]byte("hash-1"))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), epoch)
+
+		epoch, err = s.AdvanceDirectory([]byte("hash-2"))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(2), epoch)
+
+		// Going back to an earlier directory is still a new epoch.
+		epoch, err = s.AdvanceDirectory([]byte("hash-1"))
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), epoch)
+	})
+
+	t.Run("Log", func(t *testing.T) {
+		s := newStore(t)
+
+		size, err := s.LogSize()
+		assert.NoError(t, err)
+		assert.Zero(t, size)
+
+		for i, entry := range []string{"first", "second", "third"} {
+			index, err := s.AppendLog([]byte(entry))
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(i), index)
+		}
+
+		size, err = s.LogSize()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(3), size)
+
+		entries, err := s.LogEntries(1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("second"), []byte("third")}, entries)
+
+		entries, err = s.LogEntries(0, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("first")}, entries)
+
+		entries, err = s.LogEntries(3, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewMemory()
+	})
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SweepShares purges shares older than retention every interval until ctx
+// is done.
+func SweepShares(ctx context.Context, s Store, retention time.Duration, interval time.Duration) {
+	sweep(ctx, "shares", interval, func() (int, error) {
+		return s.PurgeShares(time.Now().Add(-retention))
+	})
+}
+
+// SweepChallenges purges challenges older than ttl every interval until ctx
+// is done.
+func SweepChallenges(ctx context.Context, s Store, ttl time.Duration, interval time.Duration) {
+	sweep(ctx, "challenges", interval, func() (int, error) {
+		return s.PurgeChallenges(time.Now().Add(-ttl))
+	})
+}
+
+func sweep(ctx context.Context, what string, interval time.Duration, purge func() (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := purge()
+		if err != nil {
+			log.Printf("purge %s: %v", what, err)
+		} else if purged > 0 {
+			log.Printf("purged %d expired %s", purged, what)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
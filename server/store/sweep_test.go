@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/berkmancenter/rendezvous-point/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSweepShares(t *testing.T) {
+	s := NewMemory()
+	recipient := []byte("recipient")
+	s.PutShare(recipient, Share{ID: "old", SubmittedAt: time.Now().Add(-time.Hour)})
+	s.PutShare(recipient, Share{ID: "new", SubmittedAt: time.Now()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		SweepShares(ctx, s, time.Minute, time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		shares, _ := s.Shares(recipient)
+		return len(shares) == 1 && shares[0].ID == "new"
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestSweepChallenges(t *testing.T) {
+	s := NewMemory()
+	s.PutChallenge("key", "old", types.Challenge{IssuedAt: time.Now().Add(-time.Hour)}, 5, time.Time{})
+	s.PutChallenge("key", "new", types.Challenge{IssuedAt: time.Now()}, 5, time.Time{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go SweepChallenges(ctx, s, time.Minute, time.Millisecond)
+
+	// The expired challenge stops counting toward the limit once purged.
+	assert.Eventually(t, func() bool {
+		return s.PutChallenge("key", "probe", types.Challenge{IssuedAt: time.Now()}, 2, time.Time{}) == nil
+	}, time.Second, time.Millisecond)
+
+	_, err := s.TakeChallenge("key", "old")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.TakeChallenge("key", "new")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,118 @@
+package translog
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Log keeps the hashes of every complete subtree of a growing log, so its
+// roots and proofs take O(log n) hashes rather than rehashing every entry.
+// It is safe for concurrent use.
+type Log struct {
+	mu sync.RWMutex
+	// levels[l][i] is the root of the 2^l leaves from i*2^l.
+	levels [][][]byte
+}
+
+// Append adds an entry to the end of the log.
+func (l *Log) Append(entry []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	hash := LeafHash(entry)
+	for level := 0; ; level++ {
+		if level == len(l.levels) {
+			l.levels = append(l.levels, nil)
+		}
+		l.levels[level] = append(l.levels[level], hash)
+		n := len(l.levels[level])
+		if n%2 == 1 {
+			return
+		}
+		hash = nodeHash(l.levels[level][n-2], l.levels[level][n-1])
+	}
+}
+
+// Size returns the number of entries in the log.
+func (l *Log) Size() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.size()
+}
+
+func (l *Log) size() uint64 {
+	if len(l.levels) == 0 {
+		return 0
+	}
+	return uint64(len(l.levels[0]))
+}
+
+// Head returns the head of the tree of the first size entries.
+func (l *Log) Head(size uint64) (*TreeHead, error) {
+	root, err := l.Root(size)
+	if err != nil {
+		return nil, err
+	}
+	return newTreeHead(size, root), nil
+}
+
+// Root returns the root hash of the tree of the first size entries.
+func (l *Log) Root(size uint64) ([]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if size > l.size() {
+		return nil, fmt.Errorf("size %d is larger than the log's %d", size, l.size())
+	}
+	if size == 0 {
+		return Root(nil), nil
+	}
+	return l.hash(0, int(size)), nil
+}
+
+// InclusionProof proves the entry at index is in the tree of the first
+// size entries.
+func (l *Log) InclusionProof(index uint64, size uint64) ([][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if size > l.size() {
+		return nil, fmt.Errorf("size %d is larger than the log's %d", size, l.size())
+	}
+	if index >= size {
+		return nil, fmt.Errorf("index %d is outside a tree of size %d", index, size)
+	}
+	return inclusionPath(int(index), 0, int(size), l.hash), nil
+}
+
+// ConsistencyProof proves the tree of the first size1 entries is a prefix
+// of the tree of the first size2.
+func (l *Log) ConsistencyProof(size1 uint64, size2 uint64) ([][]byte, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if size2 > l.size() {
+		return nil, fmt.Errorf("size %d is larger than the log's %d", size2, l.size())
+	}
+	if size1 > size2 {
+		return nil, fmt.Errorf("size %d is larger than the tree's %d", size1, size2)
+	}
+	if size1 == 0 || size1 == size2 {
+		return nil, nil
+	}
+	return subproof(int(size1), 0, int(size2), true, l.hash), nil
+}
+
+// hash returns the root of the n leaves from start, looking up complete
+// subtrees. RFC 9162 splits trees so that every left part is one.
+func (l *Log) hash(start int, n int) []byte {
+	if n&(n-1) == 0 && start%n == 0 {
+		level := 0
+		for 1<<level < n {
+			level++
+		}
+		return l.levels[level][start/n]
+	}
+	k := split(n)
+	return nodeHash(l.hash(start, k), l.hash(start+k, n-k))
+}
@@ -0,0 +1,139 @@
+package translog
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/berkmancenter/rendezvous-point/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = LeafHash([]byte(fmt.Sprintf("entry-%d", i)))
+	}
+	return leaves
+}
+
+func TestRoot(t *testing.T) {
+	// The empty tree and a leaf of an empty entry, from RFC 6962 test
+	// vectors.
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", hex.EncodeToString(Root(nil)))
+	assert.Equal(t, "6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d", hex.EncodeToString(LeafHash(nil)))
+
+	leaves := testLeaves(3)
+	assert.Equal(t, nodeHash(nodeHash(leaves[0], leaves[1]), leaves[2]), Root(leaves))
+}
+
+func TestInclusion(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := testLeaves(size)
+		root := Root(leaves)
+		for index := range leaves {
+			proof, err := InclusionProof(uint64(index), leaves)
+			require.NoError(t, err)
+			assert.NoError(t, VerifyInclusion(uint64(index), uint64(size), leaves[index], proof, root), "index %d of %d", index, size)
+
+			other := LeafHash([]byte("other"))
+			assert.ErrorIs(t, VerifyInclusion(uint64(index), uint64(size), other, proof, root), ErrInvalidProof)
+			if len(proof) > 0 {
+				assert.ErrorIs(t, VerifyInclusion(uint64(index), uint64(size), leaves[index], proof[1:], root), ErrInvalidProof)
+			}
+		}
+	}
+
+	_, err := InclusionProof(3, testLeaves(3))
+	assert.Error(t, err)
+	assert.ErrorIs(t, VerifyInclusion(3, 3, nil, nil, nil), ErrInvalidProof)
+}
+
+func TestConsistency(t *testing.T) {
+	for size2 := 1; size2 <= 17; size2++ {
+		leaves := testLeaves(size2)
+		root2 := Root(leaves)
+		for size1 := 0; size1 <= size2; size1++ {
+			root1 := Root(leaves[:size1])
+			proof, err := ConsistencyProof(uint64(size1), leaves)
+			require.NoError(t, err)
+			assert.NoError(t, VerifyConsistency(uint64(size1), uint64(size2), root1, root2, proof), "%d to %d", size1, size2)
+
+			if size1 > 0 && size1 < size2 {
+				// A log that rewrote its history fails.
+				forked := append(testLeaves(size1-1), LeafHash([]byte("rewritten")))
+				assert.ErrorIs(t, VerifyConsistency(uint64(size1), uint64(size2), Root(forked), root2, proof), ErrInvalidProof)
+			}
+		}
+	}
+
+	_, err := ConsistencyProof(4, testLeaves(3))
+	assert.Error(t, err)
+}
+
+func TestLog(t *testing.T) {
+	var log Log
+	var leaves [][]byte
+	for size := 0; size <= 33; size++ {
+		if size > 0 {
+			entry := []byte(fmt.Sprintf("entry-%d", size-1))
+			log.Append(entry)
+			leaves = append(leaves, LeafHash(entry))
+		}
+		require.Equal(t, uint64(size), log.Size())
+
+		// The cached hashes agree with rehashing every leaf, for this size
+		// and each earlier one.
+		for size2 := 0; size2 <= size; size2++ {
+			root, err := log.Root(uint64(size2))
+			require.NoError(t, err)
+			assert.Equal(t, Root(leaves[:size2]), root, "root of %d", size2)
+
+			for index := range size2 {
+				proof, err := log.InclusionProof(uint64(index), uint64(size2))
+				require.NoError(t, err)
+				want, _ := InclusionProof(uint64(index), leaves[:size2])
+				assert.Equal(t, want, proof, "inclusion of %d in %d", index, size2)
+			}
+			for size1 := 0; size1 <= size2; size1++ {
+				proof, err := log.ConsistencyProof(uint64(size1), uint64(size2))
+				require.NoError(t, err)
+				want, _ := ConsistencyProof(uint64(size1), leaves[:size2])
+				assert.Equal(t, want, proof, "%d to %d", size1, size2)
+			}
+		}
+	}
+
+	_, err := log.Root(34)
+	assert.Error(t, err)
+	_, err = log.InclusionProof(3, 3)
+	assert.Error(t, err)
+	_, err = log.ConsistencyProof(4, 3)
+	assert.Error(t, err)
+}
+
+func TestMonitor(t *testing.T) {
+	keys, err := keyring.New(keyring.Options{})
+	require.NoError(t, err)
+	leaves := testLeaves(10)
+
+	sign := func(size int) string {
+		signed, err := keys.Sign(NewTreeHead(leaves[:size]))
+		require.NoError(t, err)
+		return signed
+	}
+
+	m := Monitor{KeyFunc: keys.KeyFunc}
+	require.NoError(t, m.Update(sign(3), nil))
+	assert.Equal(t, uint64(3), m.Head.Size)
+
+	proof, err := ConsistencyProof(3, leaves[:7])
+	require.NoError(t, err)
+	require.NoError(t, m.Update(sign(7), proof))
+	assert.Equal(t, uint64(7), m.Head.Size)
+
+	assert.ErrorIs(t, m.Update(sign(5), nil), ErrRollback)
+	assert.ErrorIs(t, m.Update(sign(10), proof), ErrInvalidProof)
+	assert.Equal(t, uint64(7), m.Head.Size)
+}
@@ -0,0 +1,100 @@
+// Package translog is an append-only Merkle tree log with RFC 9162
+// hashing and proofs. The server builds proofs with it, and auditors use
+// it to verify them against signed tree heads.
+package translog
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/bits"
+)
+
+// LeafHash hashes one log entry.
+func LeafHash(entry []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(entry)
+	return h.Sum(nil)
+}
+
+func nodeHash(left []byte, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Root returns the root hash of the tree with the given leaf hashes.
+func Root(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		empty := sha256.Sum256(nil)
+		return empty[:]
+	case 1:
+		return leaves[0]
+	}
+	k := split(len(leaves))
+	return nodeHash(Root(leaves[:k]), Root(leaves[k:]))
+}
+
+// InclusionProof proves the leaf at index is in the tree with the given
+// leaf hashes.
+func InclusionProof(index uint64, leaves [][]byte) ([][]byte, error) {
+	if index >= uint64(len(leaves)) {
+		return nil, fmt.Errorf("index %d is outside a tree of size %d", index, len(leaves))
+	}
+	return inclusionPath(int(index), 0, len(leaves), leafRange(leaves)), nil
+}
+
+// ConsistencyProof proves the tree of the first size leaves is a prefix
+// of the tree with the given leaf hashes.
+func ConsistencyProof(size uint64, leaves [][]byte) ([][]byte, error) {
+	if size > uint64(len(leaves)) {
+		return nil, fmt.Errorf("size %d is larger than the tree's %d", size, len(leaves))
+	}
+	if size == 0 || size == uint64(len(leaves)) {
+		return nil, nil
+	}
+	return subproof(int(size), 0, len(leaves), true, leafRange(leaves)), nil
+}
+
+// rangeHash returns the root hash of the n leaves from start. Proofs only
+// ask for ranges that RFC 9162 splits a tree into.
+type rangeHash func(start int, n int) []byte
+
+func leafRange(leaves [][]byte) rangeHash {
+	return func(start int, n int) []byte {
+		return Root(leaves[start : start+n])
+	}
+}
+
+func inclusionPath(m int, start int, n int, hash rangeHash) [][]byte {
+	if n == 1 {
+		return nil
+	}
+	k := split(n)
+	if m < k {
+		return append(inclusionPath(m, start, k, hash), hash(start+k, n-k))
+	}
+	return append(inclusionPath(m-k, start+k, n-k, hash), hash(start, k))
+}
+
+func subproof(m int, start int, n int, complete bool, hash rangeHash) [][]byte {
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{hash(start, n)}
+	}
+	k := split(n)
+	if m <= k {
+		return append(subproof(m, start, k, complete, hash), hash(start+k, n-k))
+	}
+	return append(subproof(m-k, start+k, n-k, false, hash), hash(start, k))
+}
+
+// split returns the largest power of two smaller than n, for n > 1.
+func split(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
@@ -0,0 +1,171 @@
+package translog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidProof = errors.New("invalid proof")
+	ErrRollback     = errors.New("tree head is older than the last one verified")
+)
+
+// TreeHead is the signed commitment to the log at one size.
+type TreeHead struct {
+	Size uint64 `json:"size"`
+	// RootHash is the base64 encoded root hash.
+	RootHash string `json:"rootHash"`
+	jwt.RegisteredClaims
+}
+
+// NewTreeHead returns the head of the tree with the given leaf hashes.
+func NewTreeHead(leaves [][]byte) *TreeHead {
+	return newTreeHead(uint64(len(leaves)), Root(leaves))
+}
+
+func newTreeHead(size uint64, root []byte) *TreeHead {
+	return &TreeHead{
+		Size:     size,
+		RootHash: base64.StdEncoding.EncodeToString(root),
+	}
+}
+
+// Root decodes the head's root hash.
+func (h *TreeHead) Root() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(h.RootHash)
+}
+
+// ParseTreeHead verifies a signed tree head.
+func ParseTreeHead(signed string, keyFunc jwt.Keyfunc) (*TreeHead, error) {
+	var head TreeHead
+	_, err := jwt.ParseWithClaims(signed, &head, keyFunc, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := head.Root(); err != nil {
+		return nil, fmt.Errorf("invalid root hash: %w", err)
+	}
+	return &head, nil
+}
+
+// VerifyInclusion checks that proof places the leaf with leafHash at
+// index in the tree of size with root.
+func VerifyInclusion(index uint64, size uint64, leafHash []byte, proof [][]byte, root []byte) error {
+	if index >= size {
+		return fmt.Errorf("%w: index %d is outside a tree of size %d", ErrInvalidProof, index, size)
+	}
+
+	fn, sn := index, size-1
+	r := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("%w: proof too long", ErrInvalidProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// VerifyConsistency checks that proof shows the tree of size1 with root1
+// is a prefix of the tree of size2 with root2.
+func VerifyConsistency(size1 uint64, size2 uint64, root1 []byte, root2 []byte, proof [][]byte) error {
+	switch {
+	case size1 > size2:
+		return fmt.Errorf("%w: size %d is larger than %d", ErrInvalidProof, size1, size2)
+	case size1 == size2:
+		if len(proof) != 0 || !bytes.Equal(root1, root2) {
+			return ErrInvalidProof
+		}
+		return nil
+	case size1 == 0:
+		if len(proof) != 0 {
+			return ErrInvalidProof
+		}
+		return nil
+	case len(proof) == 0:
+		return ErrInvalidProof
+	}
+
+	if size1&(size1-1) == 0 {
+		proof = append([][]byte{root1}, proof...)
+	}
+
+	fn, sn := size1-1, size2-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("%w: proof too long", ErrInvalidProof)
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, root1) || !bytes.Equal(sr, root2) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// Monitor follows a log's signed tree heads, accepting each only if the
+// log it commits to extends the last one accepted.
+type Monitor struct {
+	KeyFunc jwt.Keyfunc
+	// Head is the last verified tree head, nil before the first.
+	Head *TreeHead
+}
+
+// Update verifies signed and, given a consistency proof from the current
+// head's size, makes it the current head.
+func (m *Monitor) Update(signed string, proof [][]byte) error {
+	head, err := ParseTreeHead(signed, m.KeyFunc)
+	if err != nil {
+		return err
+	}
+
+	if m.Head != nil {
+		if head.Size < m.Head.Size {
+			return ErrRollback
+		}
+		root1, _ := m.Head.Root()
+		root2, _ := head.Root()
+		if err := VerifyConsistency(m.Head.Size, head.Size, root1, root2, proof); err != nil {
+			return err
+		}
+	}
+
+	m.Head = head
+	return nil
+}
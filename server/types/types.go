@@ -1,14 +1,102 @@
 package types
 
+import "time"
+
+// ErrorCode identifies a failure independently of its message, which may
+// change.
+type ErrorCode string
+
+const (
+	ErrorInvalidBody          ErrorCode = "invalid_body"
+	ErrorInvalidKey           ErrorCode = "invalid_key"
+	ErrorInvalidParameter     ErrorCode = "invalid_parameter"
+	ErrorKeyMismatch          ErrorCode = "key_mismatch"
+	ErrorNotRegistered        ErrorCode = "not_registered"
+	ErrorAlreadyRegistered    ErrorCode = "already_registered"
+	ErrorInvalidAuthorization ErrorCode = "invalid_authorization"
+	ErrorChallengeFailed      ErrorCode = "challenge_failed"
+	ErrorChallengeExpired     ErrorCode = "challenge_expired"
+	ErrorTooManyChallenges    ErrorCode = "too_many_challenges"
+	ErrorInvalidSession       ErrorCode = "invalid_session"
+	ErrorUnsupportedProtocol  ErrorCode = "unsupported_protocol"
+	ErrorInvalidShare         ErrorCode = "invalid_share"
+	ErrorMissingCredential    ErrorCode = "missing_credential"
+	ErrorInvalidCredential    ErrorCode = "invalid_credential"
+	ErrorCredentialUsed       ErrorCode = "credential_used"
+	ErrorTokenKeyRotated      ErrorCode = "token_key_rotated"
+	ErrorTooManyTokenKeys     ErrorCode = "too_many_token_keys"
+	ErrorOrganizationUnknown  ErrorCode = "organization_unknown"
+	ErrorNotFound             ErrorCode = "not_found"
+	ErrorMethodNotAllowed     ErrorCode = "method_not_allowed"
+	ErrorBodyTooLarge         ErrorCode = "body_too_large"
+	ErrorInternal             ErrorCode = "internal_error"
+)
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	// Retryable is set when the same request may succeed if sent again
+	// later.
+	Retryable bool `json:"retryable,omitempty"`
+}
+
+// StatusResponse is the body of a successful request that returns nothing
+// else.
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
 type VerifiableShare struct {
 	Data         string `json:"data"`
 	EphemeralKey string `json:"ephemeralKey"`
 	Commitment   string `json:"commitment"`
 }
 
+// RecipientStatus is where a recipient stands in the operator's review.
+// Only approved recipients are listed in the directory.
+type RecipientStatus string
+
+const (
+	RecipientPending   RecipientStatus = "pending"
+	RecipientApproved  RecipientStatus = "approved"
+	RecipientRejected  RecipientStatus = "rejected"
+	RecipientSuspended RecipientStatus = "suspended"
+)
+
+// Valid reports whether s is a known status.
+func (s RecipientStatus) Valid() bool {
+	switch s {
+	case RecipientPending, RecipientApproved, RecipientRejected, RecipientSuspended:
+		return true
+	}
+	return false
+}
+
 type Recipient struct {
 	Name      string `json:"name"`
 	PublicKey string `json:"publicKey"`
+	// Threshold is the minimum number of shares from one organization the
+	// recipient requires before any are released.
+	Threshold int `json:"threshold,omitempty"`
+
+	// The rest is set by the operator. An empty status predates review and
+	// counts as pending.
+	Status       RecipientStatus `json:"status,omitempty"`
+	Organization string          `json:"organization,omitempty"`
+	Contact      string          `json:"contact,omitempty"`
+	// Notes records how the recipient was verified. It is never listed
+	// publicly.
+	Notes string `json:"notes,omitempty"`
+}
+
+// RecipientReview changes a recipient's status or metadata. An empty status
+// and nil fields are left as they are.
+type RecipientReview struct {
+	Status       RecipientStatus `json:"status,omitempty"`
+	Organization *string         `json:"organization,omitempty"`
+	Contact      *string         `json:"contact,omitempty"`
+	Notes        *string         `json:"notes,omitempty"`
 }
 
 type Challenge struct {
@@ -16,6 +104,7 @@ type Challenge struct {
 	EphemeralPublicKey  []byte
 	Token               []byte
 	Nonce               []byte
+	IssuedAt            time.Time
 }
 
 type ChallengeAuth struct {
@@ -23,10 +112,76 @@ type ChallengeAuth struct {
 	EncryptedToken string `json:"encryptedToken"`
 }
 
+type RotateRequest struct {
+	// PublicKey is the new key, base64 encoded.
+	PublicKey string `json:"publicKey"`
+	// Challenge answers a challenge for the new key.
+	Challenge ChallengeAuth `json:"challenge"`
+}
+
+type DirectoryResponse struct {
+	// Directory is a JWT over directory.Claims, signed by the key in
+	// /.well-known/log-jwks.json.
+	Directory string `json:"directory"`
+}
+
+// LogAction is what a transparency log entry records happening to a
+// recipient.
+type LogAction string
+
+const (
+	LogRegister   LogAction = "register"
+	LogUpdate     LogAction = "update"
+	LogReview     LogAction = "review"
+	LogRotate     LogAction = "rotate"
+	LogDeregister LogAction = "deregister"
+)
+
+type LogEntry struct {
+	Action LogAction `json:"action"`
+	// Recipient is the registration after the event, or as it was before
+	// a deregistration, without the operator's notes. Only approved
+	// listings are logged: a delisting records the listing as it was,
+	// with the new status.
+	Recipient Recipient `json:"recipient"`
+	// PreviousKey is the key a rotation moved away from.
+	PreviousKey string `json:"previousKey,omitempty"`
+	// Time is in Unix seconds.
+	Time int64 `json:"time"`
+}
+
+type TreeHeadResponse struct {
+	// TreeHead is a JWT over translog.TreeHead, signed by the key in
+	// /.well-known/log-jwks.json.
+	TreeHead string `json:"treeHead"`
+}
+
+type LogEntriesResponse struct {
+	// Entries are the exact bytes hashed into the log: JSON encoded
+	// LogEntry values.
+	Entries [][]byte `json:"entries"`
+}
+
+type InclusionProofResponse struct {
+	Index    uint64   `json:"index"`
+	TreeSize uint64   `json:"treeSize"`
+	Entry    []byte   `json:"entry"`
+	Proof    [][]byte `json:"proof"`
+}
+
+type ConsistencyProofResponse struct {
+	First  uint64   `json:"first"`
+	Second uint64   `json:"second"`
+	Proof  [][]byte `json:"proof"`
+}
+
 type DisclosureRequest struct {
 	ID              string          `json:"id"`
 	Recipient       string          `json:"recipient"`
 	VerifiableShare VerifiableShare `json:"verifiableShare"`
+	// Protocol is the version of the share format and commitment scheme.
+	// Zero means 1, for clients that predate versioning.
+	Protocol int `json:"protocol,omitempty"`
 }
 
 type InboxChallengeResponse struct {
@@ -35,8 +190,85 @@ type InboxChallengeResponse struct {
 	Nonce     string `json:"nonce"`
 }
 
+type SessionResponse struct {
+	Session string `json:"session"`
+	// ExpiresAt is in Unix seconds.
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
 type InboxResponse struct {
 	ID              string          `json:"id"`
 	Org             string          `json:"org"`
 	VerifiableShare VerifiableShare `json:"verifiableShare"`
+	Protocol        int             `json:"protocol"`
+}
+
+type InfoResponse struct {
+	// APIVersions are the path prefixes served, such as "v1".
+	APIVersions []string `json:"apiVersions"`
+	// Protocols are the disclosure protocol versions POST /disclose
+	// accepts.
+	Protocols  []int           `json:"protocols"`
+	Thresholds ThresholdPolicy `json:"thresholds"`
+	Limits     Limits          `json:"limits"`
+	// KeyIDs identify the keys in /.well-known/jwks.json.
+	KeyIDs []string `json:"keyIds"`
+}
+
+type ThresholdPolicy struct {
+	Default int            `json:"default"`
+	Orgs    map[string]int `json:"orgs,omitempty"`
+	// Window is how recent shares must be to count toward a threshold, in
+	// seconds. Zero counts every share.
+	Window int64 `json:"window"`
+}
+
+// Limits are in bytes, seconds or counts.
+type Limits struct {
+	MaxBodyBytes       int   `json:"maxBodyBytes"`
+	CredentialLifetime int64 `json:"credentialLifetime"`
+	ChallengeTTL       int64 `json:"challengeTtl"`
+	MaxChallenges      int   `json:"maxChallenges"`
+	SessionLifetime    int64 `json:"sessionLifetime"`
+	MaxLogEntries      int   `json:"maxLogEntries"`
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	KeyID     string `json:"kid,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Use       string `json:"use,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type TokenKeyResponse struct {
+	Organization string `json:"organization"`
+	Epoch        int64  `json:"epoch"`
+	PublicKey    string `json:"publicKey"`
+}
+
+type TokenRequest struct {
+	Epoch          int64  `json:"epoch"`
+	BlindedMessage string `json:"blindedMessage"`
+}
+
+type TokenResponse struct {
+	Organization   string `json:"organization"`
+	Epoch          int64  `json:"epoch"`
+	BlindSignature string `json:"blindSignature"`
+}
+
+// Token is an unblinded credential, presented on /disclose as
+// "Authorization: Token <base64 JSON>".
+type Token struct {
+	Organization string `json:"organization"`
+	Epoch        int64  `json:"epoch"`
+	Message      string `json:"message"`
+	Signature    string `json:"signature"`
 }